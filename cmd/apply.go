@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+)
+
+// applyConflictExitCode is the exit code of a patch that does not apply
+// to a file that drifted from the base the patch was made from
+const applyConflictExitCode = 1
+
+var (
+	applyOutput  string
+	applyReverse bool
+)
+
+// applyCmd applies a patch written by --format patch to a csv file
+var applyCmd = &cobra.Command{
+	Use:          "apply <base-csv> <patch>",
+	SilenceUsage: true,
+	Short:        "Apply a patch written by --format patch to a csv file",
+	Long: `Applies a patch written by --format patch to base-csv. Deleted and
+updated rows must still have the values the patch was made from, otherwise
+nothing is written. Rows are kept in the order of base-csv and added rows
+follow. Pass --reverse to undo the patch on delta-csv.
+
+Exits with 0 if the patch is applied, 1 if it does not apply and 2 if it fails.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runeSeparator, err := parseSeparator(separator)
+		if err != nil {
+			return err
+		}
+
+		err = runApply(afero.NewOsFs(), args[0], args[1], runeSeparator, os.Stdout)
+		var conflict *digest.PatchConflictError
+		if errors.As(err, &conflict) {
+			return &exitError{code: applyConflictExitCode, err: err}
+		}
+		if err != nil {
+			return &exitError{code: errorExitCode, err: err}
+		}
+		return nil
+	},
+}
+
+// runApply applies the patch in patchFilename to filename
+// and writes the rows to applyOutput or to outputStream
+func runApply(fs afero.Fs, filename, patchFilename string, separator rune, outputStream io.Writer) error {
+	patchFile, err := fs.Open(patchFilename)
+	if err != nil {
+		return err
+	}
+	defer patchFile.Close()
+
+	patch, err := digest.ReadPatch(patchFile)
+	if err != nil {
+		return fmt.Errorf("error in patch: %v", err)
+	}
+	if applyReverse {
+		patch = patch.Reverse()
+	}
+
+	recordCount, err := getColumnsCount(fs, filename, separator, lazyQuotes)
+	if err != nil {
+		return fmt.Errorf("error in csv: %v", err)
+	}
+	if !assertAll(patch.Key, func(element int) bool { return element < recordCount }) {
+		return fmt.Errorf("primary key positions of the patch are out of bounds")
+	}
+
+	file, err := fs.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var patched bytes.Buffer
+	config := digest.Config{
+		Reader:     file,
+		Separator:  separator,
+		LazyQuotes: lazyQuotes,
+	}
+	if err := digest.Apply(config, patch, &patched); err != nil {
+		return err
+	}
+
+	return writeOutput(fs, applyOutput, &patched, outputStream)
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringVarP(&applyOutput, "output", "o", "", "File to write the patched csv to. Default is stdout")
+	applyCmd.Flags().BoolVar(&applyReverse, "reverse", false, "Undo the patch")
+	addCsvFlags(applyCmd)
+}
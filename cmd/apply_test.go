@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunApply(t *testing.T) {
+	base := "id,name,age\n1,tom,2\n2,ryan,20\n"
+	delta := "id,name,age\n1,tom,2\n3,emin,30\n"
+
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/base.csv", []byte(base), os.ModePerm))
+	assert.NoError(t, afero.WriteFile(fs, "/delta.csv", []byte(delta), os.ModePerm))
+
+	ctx, err := NewContext(fs, digest.Positions{0}, nil, nil, nil, "patch", "/base.csv", "/delta.csv", ',', false)
+	assert.NoError(t, err)
+	patch := &bytes.Buffer{}
+	_, err = runContext(context.Background(), ctx, patch, &bytes.Buffer{})
+	assert.NoError(t, err)
+	assert.NoError(t, afero.WriteFile(fs, "/base.patch", patch.Bytes(), os.ModePerm))
+
+	t.Run("should turn base into delta", func(t *testing.T) {
+		outStream := &bytes.Buffer{}
+		err := runApply(fs, "/base.csv", "/base.patch", ',', outStream)
+
+		assert.NoError(t, err)
+		assert.Equal(t, delta, outStream.String())
+	})
+
+	t.Run("should turn delta back into base in place", func(t *testing.T) {
+		assert.NoError(t, afero.WriteFile(fs, "/rollback.csv", []byte(delta), os.ModePerm))
+		applyOutput, applyReverse = "/rollback.csv", true
+		defer func() { applyOutput, applyReverse = "", false }()
+
+		err := runApply(fs, "/rollback.csv", "/base.patch", ',', &bytes.Buffer{})
+
+		assert.NoError(t, err)
+		rolledBack, err := afero.ReadFile(fs, "/rollback.csv")
+		assert.NoError(t, err)
+		assert.Equal(t, base, string(rolledBack))
+	})
+
+	t.Run("should turn base into delta whatever columns are compared", func(t *testing.T) {
+		assert.NoError(t, afero.WriteFile(fs, "/renamed.csv", []byte("id,name,age\n1,thomas,2\n3,emin,30\n"), os.ModePerm))
+		ctx, err := NewContext(fs, digest.Positions{0}, digest.Positions{2}, nil, nil, "patch", "/base.csv", "/renamed.csv", ',', false)
+		assert.NoError(t, err)
+		patch := &bytes.Buffer{}
+		_, err = runContext(context.Background(), ctx, patch, &bytes.Buffer{})
+		assert.NoError(t, err)
+		assert.NoError(t, afero.WriteFile(fs, "/renamed.patch", patch.Bytes(), os.ModePerm))
+
+		outStream := &bytes.Buffer{}
+		err = runApply(fs, "/base.csv", "/renamed.patch", ',', outStream)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "id,name,age\n1,thomas,2\n3,emin,30\n", outStream.String())
+	})
+
+	t.Run("should not patch a file that drifted", func(t *testing.T) {
+		err := runApply(fs, "/delta.csv", "/base.patch", ',', &bytes.Buffer{})
+
+		assert.EqualError(t, err, `patch does not apply: row "3" to add already exists on line 3`)
+		assert.IsType(t, &digest.PatchConflictError{}, err)
+	})
+
+	t.Run("should need the rows of a snapshot", func(t *testing.T) {
+		assert.NoError(t, runSnapshot(fs, "/base.csv", "/base.digest", ','))
+
+		_, err := NewContext(fs, digest.Positions{0}, nil, nil, nil, "patch", "/base.digest", "/delta.csv", ',', false)
+
+		assert.EqualError(t, err, "validation failed: a patch needs the original rows. Take the snapshot with --rows")
+	})
+}
@@ -21,10 +21,32 @@ type Context struct {
 	baseFilename           string
 	deltaFilename          string
 	baseFile               afero.File
+	baseSnapshot           bool
+	baseSnapshotRows       bool
 	deltaFile              afero.File
 	recordCount            int
+	header                 []string
+	hasHeader              bool
+	stats                  bool
+	sample                 float64
+	quiet                  bool
+	limits                 []limit
+	baseRows               baseRowCounter
+	keyless                bool
+	lineNumbers            bool
 	separator              rune
 	lazyQuotes             bool
+	duplicates             digest.DuplicatePolicy
+	verify                 bool
+	maxMemory              int64
+	spillDir               string
+	sorted                 bool
+	collation              digest.Collation
+	index                  bool
+	rekeys                 bool
+	reorders               bool
+	order                  digest.Order
+	progress               *progressBar
 }
 
 // NewContext can take all CLI flags and create a cmd.Context
@@ -42,15 +64,20 @@ func NewContext(
 	separator rune,
 	lazyQuotes bool,
 ) (*Context, error) {
-	baseRecordCount, err := getColumnsCount(fs, baseFilename, separator, lazyQuotes)
+	snapshot, baseSnapshot, err := getSnapshot(fs, baseFilename)
+	baseRecordCount := snapshot.Columns
+	if err == nil && !baseSnapshot {
+		baseRecordCount, err = getColumnsCount(fs, baseFilename, separator, lazyQuotes)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error in base-file: %v", err)
 	}
 
-	deltaRecordCount, err := getColumnsCount(fs, deltaFilename, separator, lazyQuotes)
+	header, err := getHeader(fs, deltaFilename, separator, lazyQuotes)
 	if err != nil {
 		return nil, fmt.Errorf("error in delta-file: %v", err)
 	}
+	deltaRecordCount := len(header)
 
 	if baseRecordCount != deltaRecordCount {
 		return nil, fmt.Errorf("base-file and delta-file columns count do not match")
@@ -80,8 +107,11 @@ func NewContext(
 		baseFilename:           baseFilename,
 		deltaFilename:          deltaFilename,
 		baseFile:               baseFile,
+		baseSnapshot:           baseSnapshot,
+		baseSnapshotRows:       snapshot.Rows,
 		deltaFile:              deltaFile,
 		recordCount:            baseRecordCount,
+		header:                 header,
 		separator:              separator,
 		lazyQuotes:             lazyQuotes,
 	}
@@ -133,6 +163,9 @@ func (c *Context) validate() error {
 		if !formatFound {
 			return fmt.Errorf("specified format is not valid")
 		}
+		if strings.ToLower(c.format) == patchFormat && c.baseSnapshot && !c.baseSnapshotRows {
+			return fmt.Errorf("a patch needs the original rows. Take the snapshot with --rows")
+		}
 	}
 
 	{
@@ -182,9 +215,15 @@ func assertAll(elements []int, assertFn func(element int) bool) bool {
 }
 
 func getColumnsCount(fs afero.Fs, filename string, separator rune, lazyQuotes bool) (int, error) {
+	header, err := getHeader(fs, filename, separator, lazyQuotes)
+	return len(header), err
+}
+
+// getHeader returns the first row of filename
+func getHeader(fs afero.Fs, filename string, separator rune, lazyQuotes bool) ([]string, error) {
 	base, err := fs.Open(filename)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer base.Close()
 	csvReader := csv.NewReader(base)
@@ -193,12 +232,32 @@ func getColumnsCount(fs afero.Fs, filename string, separator rune, lazyQuotes bo
 	record, err := csvReader.Read()
 	if err != nil {
 		if err == io.EOF {
-			return 0, fmt.Errorf("unable to process headers from csv file. EOF reached. invalid CSV file")
+			return nil, fmt.Errorf("unable to process headers from csv file. EOF reached. invalid CSV file")
 		}
-		return 0, err
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// getSnapshot returns how the snapshot in filename was taken.
+// It is false if filename is not a snapshot.
+func getSnapshot(fs afero.Fs, filename string) (digest.Snapshot, bool, error) {
+	file, err := fs.Open(filename)
+	if err != nil {
+		return digest.Snapshot{}, false, err
+	}
+	defer file.Close()
+
+	snapshot, err := digest.ReadSnapshot(file)
+	if err == digest.ErrNotSnapshot {
+		return digest.Snapshot{}, false, nil
+	}
+	if err != nil {
+		return digest.Snapshot{}, false, err
 	}
 
-	return len(record), nil
+	return snapshot, true, nil
 }
 
 // BaseDigestConfig creates a digest.Context from cmd.Context
@@ -206,27 +265,91 @@ func getColumnsCount(fs afero.Fs, filename string, separator rune, lazyQuotes bo
 func (c *Context) BaseDigestConfig() (digest.Config, error) {
 	return digest.Config{
 		Reader:     c.baseFile,
-		Value:      c.valueColumnPositions,
+		Value:      c.comparedColumns(),
 		Key:        c.primaryKeyPositions,
 		Include:    c.includeColumnPositions,
 		Separator:  c.separator,
 		LazyQuotes: c.lazyQuotes,
+		Duplicates: c.duplicates,
+		Verify:     c.verify,
+		MaxMemory:  c.maxMemory,
+		SpillDir:   c.spillDir,
+		Sorted:     c.sorted,
+		Collation:  c.collation,
+		Order:      c.order,
+		Progress:   c.progressHook(),
+		Index:      c.index,
+		Rekeys:     c.rekeys,
+		Reorders:   c.reorders,
+		Snapshot:   c.baseSnapshot,
+		Header:     c.columnNames(),
+		SkipHeader: c.hasHeader,
+		Sample:     c.sample,
 	}, nil
 }
 
+// comparedColumns returns the --columns positions. A patch has to turn
+// base into delta, so it compares every column whatever --columns says.
+func (c *Context) comparedColumns() digest.Positions {
+	if strings.EqualFold(c.format, patchFormat) {
+		return nil
+	}
+	return c.valueColumnPositions
+}
+
+// progressHook returns the digest.Config.Progress drawing the progress bar
+// and, if a limit is a percentage of the rows in base, counting them
+func (c *Context) progressHook() func(digest.Progress) {
+	for _, l := range c.limits {
+		if l.percent {
+			return c.baseRows.hook(c.progress.hook())
+		}
+	}
+	return c.progress.hook()
+}
+
+// columnNames returns the names of the columns
+// if the files have a header and nil otherwise
+func (c *Context) columnNames() []string {
+	if !c.hasHeader {
+		return nil
+	}
+	return c.header
+}
+
 // DeltaDigestConfig creates a digest.Context from cmd.Context
 // that is needed to start the diff process
 func (c *Context) DeltaDigestConfig() (digest.Config, error) {
 	return digest.Config{
 		Reader:     c.deltaFile,
-		Value:      c.valueColumnPositions,
+		Value:      c.comparedColumns(),
 		Key:        c.primaryKeyPositions,
 		Include:    c.includeColumnPositions,
 		Separator:  c.separator,
 		LazyQuotes: c.lazyQuotes,
+		Duplicates: c.duplicates,
+		Verify:     c.verify,
+		MaxMemory:  c.maxMemory,
+		SpillDir:   c.spillDir,
+		Sorted:     c.sorted,
+		Order:      c.order,
+		SkipHeader: c.hasHeader,
 	}, nil
 }
 
+// totalSize returns the size of both files in bytes, or 0 if it is unknown
+func (c *Context) totalSize() int64 {
+	var total int64
+	for _, file := range []afero.File{c.baseFile, c.deltaFile} {
+		info, err := file.Stat()
+		if err != nil {
+			return 0
+		}
+		total += info.Size()
+	}
+	return total
+}
+
 // Close all file handles
 func (c *Context) Close() {
 	if c.baseFile != nil {
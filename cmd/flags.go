@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// The subcommands read csv files like the diff does. These add the
+// flags they share with it, bound to the same variables.
+
+// addCsvFlags adds --separator and --lazyquotes to cmd
+func addCsvFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&separator, "separator", "s", ",", "use specific separator (\\t, or any one character string)")
+	cmd.Flags().BoolVar(&lazyQuotes, "lazyquotes", false, "allow unescaped quotes")
+}
+
+// addPrimaryKeyFlag adds --primary-key to cmd
+func addPrimaryKeyFlag(cmd *cobra.Command) {
+	cmd.Flags().IntSliceVarP(&primaryKeyPositions, "primary-key", "p", []int{0}, "Primary key positions of the Input CSV as comma separated values Eg: 1,2")
+}
+
+// addColumnsFlags adds --columns to cmd and --ignore-columns if ignore is set
+func addColumnsFlags(cmd *cobra.Command, ignore bool) {
+	cmd.Flags().IntSliceVarP(&valueColumnPositions, "columns", "", []int{}, "Selectively compare positions in CSV Eg: 1,2. Default is entire row")
+	if ignore {
+		cmd.Flags().IntSliceVarP(&ignoreValueColumnPositions, "ignore-columns", "", []int{}, "Inverse of --columns flag. This cannot be used if --columns are specified")
+	}
+}
+
+// addHeaderFlag adds --header to cmd. Like for the diff, files have no header by default.
+func addHeaderFlag(cmd *cobra.Command, usage string) {
+	cmd.Flags().BoolVar(&hasHeader, "header", false, usage)
+}
+
+// writeOutput writes the output of a command to the file output,
+// or to outputStream if output is empty. An input is often the output
+// as well, so commands buffer their output until every input is read.
+func writeOutput(fs afero.Fs, output string, buffer *bytes.Buffer, outputStream io.Writer) error {
+	if output == "" {
+		_, err := buffer.WriteTo(outputStream)
+		return err
+	}
+	return afero.WriteFile(fs, output, buffer.Bytes(), 0644)
+}
@@ -3,9 +3,13 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
 	"github.com/aswinkarthik/csvdiff/pkg/digest"
 	"github.com/fatih/color"
-	"io"
 )
 
 const (
@@ -15,9 +19,10 @@ const (
 	lineDiff         = "diff"
 	wordDiff         = "word-diff"
 	colorWords       = "color-words"
+	patchFormat      = "patch"
 )
 
-var allFormats = []string{rowmark, jsonFormat, legacyJSONFormat, lineDiff, wordDiff, colorWords}
+var allFormats = []string{rowmark, jsonFormat, legacyJSONFormat, lineDiff, wordDiff, colorWords, patchFormat}
 
 // Formatter can print the differences to stdout
 // and accompanying metadata to stderr
@@ -38,6 +43,11 @@ func NewFormatter(stdout, stderr io.Writer, ctx Context) *Formatter {
 // Format can be used to format the differences based on ctx
 // to appropriate writers
 func (f *Formatter) Format(diff digest.Differences) error {
+	if f.ctx.format != jsonFormat {
+		defer f.estimates(diff)
+		defer f.duplicates(diff)
+	}
+
 	switch f.ctx.format {
 	case legacyJSONFormat:
 		return f.legacyJSON(diff)
@@ -51,11 +61,214 @@ func (f *Formatter) Format(diff digest.Differences) error {
 		return f.wordDiff(diff)
 	case colorWords:
 		return f.colorWords(diff)
+	case patchFormat:
+		return f.patch(diff)
 	default:
 		return fmt.Errorf("formatter not found")
 	}
 }
 
+// FormatCounts prints the rows added and deleted by a --keyless diff
+// with how many times each was, or as JSON if the format is json
+func (f *Formatter) FormatCounts(diff digest.CountDifferences) error {
+	if f.ctx.format == jsonFormat {
+		return f.countsJSON(diff)
+	}
+	return f.countsDiff(diff)
+}
+
+// countsDiff prints the rows like lineDiff followed by their count if
+// it is more than one. The headers count every time a row was added or deleted.
+func (f *Formatter) countsDiff(diff digest.CountDifferences) error {
+	includes := f.ctx.GetIncludeColumnPositions()
+
+	blue := color.New(color.FgBlue).FprintfFunc()
+	red := color.New(color.FgRed).FprintfFunc()
+	green := color.New(color.FgGreen).FprintfFunc()
+
+	row := func(count digest.RowCount) string {
+		if count.Count == 1 {
+			return includes.String(count.Row, f.ctx.separator)
+		}
+		return fmt.Sprintf("%s (%d times)", includes.String(count.Row, f.ctx.separator), count.Count)
+	}
+
+	blue(f.stderr, "# Additions (%d)\n", totalCount(diff.Additions))
+	for _, addition := range diff.Additions {
+		f.hunkHeader(digest.Position{}, addition.Position)
+		green(f.stdout, "+ %s\n", row(addition))
+	}
+	blue(f.stderr, "# Deletions (%d)\n", totalCount(diff.Deletions))
+	for _, deletion := range diff.Deletions {
+		f.hunkHeader(deletion.Position, digest.Position{})
+		red(f.stdout, "- %s\n", row(deletion))
+	}
+
+	return nil
+}
+
+// totalCount is the number of times the rows of counts were added or deleted
+func totalCount(counts []digest.RowCount) int {
+	total := 0
+	for _, c := range counts {
+		total += c.Count
+	}
+	return total
+}
+
+// countsJSON formats the rows of a --keyless diff as a JSON Object
+// { "Additions": [...], "Deletions": [...] }
+func (f *Formatter) countsJSON(diff digest.CountDifferences) error {
+	includes := f.ctx.GetIncludeColumnPositions()
+
+	type row struct {
+		Row    string
+		Count  int
+		Line   int
+		Offset int64
+	}
+
+	rows := func(counts []digest.RowCount) []row {
+		result := make([]row, 0, len(counts))
+		for _, c := range counts {
+			result = append(result, row{
+				Row:    includes.String(c.Row, f.ctx.separator),
+				Count:  c.Count,
+				Line:   c.Position.Line,
+				Offset: c.Position.Offset,
+			})
+		}
+		return result
+	}
+
+	data, err := json.MarshalIndent(struct {
+		Additions []row
+		Deletions []row
+	}{rows(diff.Additions), rows(diff.Deletions)}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error when serializing with JSON formatter: %v", err)
+	}
+
+	if _, err := f.stdout.Write(data); err != nil {
+		return fmt.Errorf("error when writing to writer with JSON formatter: %v", err)
+	}
+
+	return nil
+}
+
+// FormatStats prints the counts of stats as a table,
+// or as JSON if the format is json
+func (f *Formatter) FormatStats(stats digest.Stats) error {
+	if f.ctx.format == jsonFormat {
+		return f.statsJSON(stats)
+	}
+	return f.statsTable(stats)
+}
+
+// statsTable prints the row counts followed by a row per changed column
+func (f *Formatter) statsTable(stats digest.Stats) error {
+	w := tabwriter.NewWriter(f.stdout, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintf(w, "Additions\t%d\n", stats.Additions)
+	_, _ = fmt.Fprintf(w, "Modifications\t%d\n", stats.Modifications)
+	_, _ = fmt.Fprintf(w, "Deletions\t%d\n", stats.Deletions)
+	_, _ = fmt.Fprintf(w, "Unchanged\t%d\n", stats.Unchanged)
+	if stats.Duplicates > 0 {
+		_, _ = fmt.Fprintf(w, "Duplicates\t%d\n", stats.Duplicates)
+	}
+	if f.ctx.rekeys {
+		_, _ = fmt.Fprintf(w, "Rekeyings\t%d\n", stats.Rekeyings)
+	}
+	if f.ctx.reorders {
+		_, _ = fmt.Fprintf(w, "Moves\t%d\n", stats.Moves)
+	}
+	if stats.Unknown > 0 {
+		_, _ = fmt.Fprintf(w, "Modifications without original row\t%d\n", stats.Unknown)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error when writing stats: %v", err)
+	}
+
+	if len(stats.Columns) == 0 {
+		return nil
+	}
+
+	_, _ = fmt.Fprintln(f.stdout)
+	w = tabwriter.NewWriter(f.stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprintln(w, "Column\tName\tCompared\tChanges\t% of modifications\tEmpty to value\tValue to empty\t")
+	for _, column := range stats.Columns {
+		compared := "no"
+		if column.Compared {
+			compared = "yes"
+		}
+		share := 100 * float64(column.Changes) / float64(stats.Modifications)
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%.1f\t%d\t%d\t\n",
+			column.Index, column.Name, compared, column.Changes, share, column.FromEmpty, column.ToEmpty)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error when writing stats: %v", err)
+	}
+
+	return nil
+}
+
+// statsJSON prints stats as a JSON Object
+// { "Additions": 1, ..., "Columns": [{ "Index": 2, "Changes": 3, ... }] }
+func (f *Formatter) statsJSON(stats digest.Stats) error {
+	type column struct {
+		Index     int
+		Name      string `json:",omitempty"`
+		Compared  bool
+		Changes   int
+		FromEmpty int
+		ToEmpty   int
+	}
+
+	type jsonStats struct {
+		Additions     int
+		Modifications int
+		Deletions     int
+		Unchanged     int
+		Duplicates    int
+		Rekeyings     *int `json:",omitempty"`
+		Moves         *int `json:",omitempty"`
+		Unknown       int
+		Columns       []column
+	}
+
+	columns := make([]column, 0, len(stats.Columns))
+	for _, c := range stats.Columns {
+		columns = append(columns, column(c))
+	}
+
+	jsonStat := jsonStats{
+		Additions:     stats.Additions,
+		Modifications: stats.Modifications,
+		Deletions:     stats.Deletions,
+		Unchanged:     stats.Unchanged,
+		Duplicates:    stats.Duplicates,
+		Unknown:       stats.Unknown,
+		Columns:       columns,
+	}
+	if f.ctx.rekeys {
+		jsonStat.Rekeyings = &stats.Rekeyings
+	}
+	if f.ctx.reorders {
+		jsonStat.Moves = &stats.Moves
+	}
+
+	data, err := json.MarshalIndent(jsonStat, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error when serializing stats with JSON formatter: %v", err)
+	}
+
+	if _, err := f.stdout.Write(data); err != nil {
+		return fmt.Errorf("error when writing to writer with JSON formatter: %v", err)
+	}
+
+	return nil
+}
+
 // JSONFormatter formats diff to as a JSON Object
 // { "Additions": [...], "Modifications": [...] }
 func (f *Formatter) legacyJSON(diff digest.Differences) error {
@@ -64,13 +277,15 @@ func (f *Formatter) legacyJSON(diff digest.Differences) error {
 		Additions     []string
 		Modifications []string
 		Deletions     []string
+		Rekeyings     []string `json:",omitempty"`
+		Moves         []string `json:",omitempty"`
 	}
 
 	includes := f.ctx.GetIncludeColumnPositions()
 
 	additions := make([]string, 0, len(diff.Additions))
 	for _, addition := range diff.Additions {
-		additions = append(additions, includes.String(addition, f.ctx.separator))
+		additions = append(additions, includes.String(addition.Row, f.ctx.separator))
 	}
 
 	modifications := make([]string, 0, len(diff.Modifications))
@@ -80,10 +295,20 @@ func (f *Formatter) legacyJSON(diff digest.Differences) error {
 
 	deletions := make([]string, 0, len(diff.Deletions))
 	for _, deletion := range diff.Deletions {
-		deletions = append(deletions, includes.String(deletion, f.ctx.separator))
+		deletions = append(deletions, includes.String(deletion.Row, f.ctx.separator))
+	}
+
+	var rekeyings []string
+	for _, rekeying := range diff.Rekeyings {
+		rekeyings = append(rekeyings, includes.String(rekeying.Current, f.ctx.separator))
+	}
+
+	var moves []string
+	for _, move := range diff.Moves {
+		moves = append(moves, includes.String(move.Row, f.ctx.separator))
 	}
 
-	jsonDiff := jsonDifference{Additions: additions, Modifications: modifications, Deletions: deletions}
+	jsonDiff := jsonDifference{Additions: additions, Modifications: modifications, Deletions: deletions, Rekeyings: rekeyings, Moves: moves}
 	data, err := json.MarshalIndent(jsonDiff, "", "  ")
 
 	if err != nil {
@@ -100,38 +325,165 @@ func (f *Formatter) legacyJSON(diff digest.Differences) error {
 }
 
 // JSONFormatter formats diff to as a JSON Object
-// { "Additions": [...], "Modifications": [{ "Original": [...], "Current": [...]}]}
+// { "Additions": [{ "Row": "...", "Line": 2, "Offset": 10 }], "Modifications": [{ "Original": "...", "Current": "...", ...}], "Hash": "xxhash64"}
 func (f *Formatter) json(diff digest.Differences) error {
 	includes := f.ctx.GetIncludeColumnPositions()
 
+	// positions are kept apart from the rows,
+	// which stay strings as they always were
+	type position struct {
+		Line   int
+		Offset int64
+	}
+
 	additions := make([]string, 0, len(diff.Additions))
+	additionPositions := make([]position, 0, len(diff.Additions))
 	for _, addition := range diff.Additions {
-		additions = append(additions, includes.String(addition, f.ctx.separator))
+		additions = append(additions, includes.String(addition.Row, f.ctx.separator))
+		additionPositions = append(additionPositions, position(addition.Position))
 	}
 
 	deletions := make([]string, 0, len(diff.Deletions))
+	deletionPositions := make([]position, 0, len(diff.Deletions))
 	for _, deletion := range diff.Deletions {
-		deletions = append(deletions, includes.String(deletion, f.ctx.separator))
+		deletions = append(deletions, includes.String(deletion.Row, f.ctx.separator))
+		deletionPositions = append(deletionPositions, position(deletion.Position))
+	}
+
+	type column struct {
+		Index    int
+		Name     string `json:",omitempty"`
+		Compared bool
 	}
 
 	type modification struct {
-		Original string
-		Current  string
+		Original       string
+		Current        string
+		OriginalLine   int
+		OriginalOffset int64
+		CurrentLine    int
+		CurrentOffset  int64
+		Columns        []column `json:",omitempty"`
+	}
+
+	type duplicate struct {
+		File  string
+		Key   string
+		Lines []int
+	}
+
+	type rekeying struct {
+		OriginalKey    string
+		CurrentKey     string
+		Original       string
+		Current        string
+		OriginalLine   int
+		OriginalOffset int64
+		CurrentLine    int
+		CurrentOffset  int64
+	}
+
+	type move struct {
+		Row            string
+		OriginalIndex  int
+		CurrentIndex   int
+		OriginalLine   int
+		OriginalOffset int64
+		CurrentLine    int
+		CurrentOffset  int64
+	}
+
+	type sample struct {
+		Fraction      float64
+		Additions     digest.Estimate
+		Modifications digest.Estimate
+		Deletions     digest.Estimate
 	}
 
 	type jsonDifference struct {
-		Additions     []string
-		Modifications []modification
-		Deletions     []string
+		Additions         []string
+		AdditionPositions []position
+		Modifications     []modification
+		Deletions         []string
+		DeletionPositions []position
+		Duplicates        []duplicate `json:",omitempty"`
+		Rekeyings         []rekeying  `json:",omitempty"`
+		Moves             []move      `json:",omitempty"`
+		Hash              string
+		Verified          bool
+		Sample            *sample `json:",omitempty"`
 	}
 
 	modifications := make([]modification, 0, len(diff.Modifications))
 	for _, mods := range diff.Modifications {
-		m := modification{Original: includes.String(mods.Original, f.ctx.separator), Current: includes.String(mods.Current, f.ctx.separator)}
-		modifications = append(modifications, m)
+		var columns []column
+		for _, c := range mods.Columns {
+			columns = append(columns, column{Index: c.Index, Name: c.Name, Compared: c.Compared})
+		}
+		modifications = append(modifications, modification{
+			Original:       includes.String(mods.Original, f.ctx.separator),
+			Current:        includes.String(mods.Current, f.ctx.separator),
+			OriginalLine:   mods.OriginalPosition.Line,
+			OriginalOffset: mods.OriginalPosition.Offset,
+			CurrentLine:    mods.CurrentPosition.Line,
+			CurrentOffset:  mods.CurrentPosition.Offset,
+			Columns:        columns,
+		})
+	}
+
+	var duplicates []duplicate
+	for _, d := range diff.Duplicates {
+		duplicates = append(duplicates, duplicate{File: d.File.String(), Key: digest.Positions{}.String(d.Key, f.ctx.separator), Lines: d.Lines})
+	}
+
+	var rekeyings []rekeying
+	for _, r := range diff.Rekeyings {
+		rekeyings = append(rekeyings, rekeying{
+			OriginalKey:    digest.Positions{}.String(r.OriginalKey, f.ctx.separator),
+			CurrentKey:     digest.Positions{}.String(r.CurrentKey, f.ctx.separator),
+			Original:       includes.String(r.Original, f.ctx.separator),
+			Current:        includes.String(r.Current, f.ctx.separator),
+			OriginalLine:   r.OriginalPosition.Line,
+			OriginalOffset: r.OriginalPosition.Offset,
+			CurrentLine:    r.CurrentPosition.Line,
+			CurrentOffset:  r.CurrentPosition.Offset,
+		})
 	}
 
-	data, err := json.MarshalIndent(jsonDifference{Additions: additions, Modifications: modifications, Deletions: deletions}, "", "  ")
+	var moves []move
+	for _, m := range diff.Moves {
+		moves = append(moves, move{
+			Row:            includes.String(m.Row, f.ctx.separator),
+			OriginalIndex:  m.OriginalIndex,
+			CurrentIndex:   m.CurrentIndex,
+			OriginalLine:   m.OriginalPosition.Line,
+			OriginalOffset: m.OriginalPosition.Offset,
+			CurrentLine:    m.CurrentPosition.Line,
+			CurrentOffset:  m.CurrentPosition.Offset,
+		})
+	}
+
+	jsonDiff := jsonDifference{
+		Additions:         additions,
+		AdditionPositions: additionPositions,
+		Modifications:     modifications,
+		Deletions:         deletions,
+		DeletionPositions: deletionPositions,
+		Duplicates:        duplicates,
+		Rekeyings:         rekeyings,
+		Moves:             moves,
+		Hash:              digest.HashAlgorithm,
+		Verified:          f.ctx.verify,
+	}
+	if f.ctx.sample > 0 {
+		jsonDiff.Sample = &sample{
+			Fraction:      f.ctx.sample,
+			Additions:     digest.EstimateCount(len(diff.Additions), f.ctx.sample),
+			Modifications: digest.EstimateCount(len(diff.Modifications), f.ctx.sample),
+			Deletions:     digest.EstimateCount(len(diff.Deletions), f.ctx.sample),
+		}
+	}
+	data, err := json.MarshalIndent(jsonDiff, "", "  ")
 
 	if err != nil {
 		return fmt.Errorf("error when serializing with JSON formatter: %v", err)
@@ -146,19 +498,36 @@ func (f *Formatter) json(diff digest.Differences) error {
 	return nil
 }
 
+// patch writes the patch turning base into delta,
+// to be applied with csvdiff apply
+func (f *Formatter) patch(diff digest.Differences) error {
+	patch, err := digest.NewPatch(diff, f.ctx.GetPrimaryKeys())
+	if err != nil {
+		return err
+	}
+
+	return digest.WritePatch(f.stdout, patch)
+}
+
 // RowMarkFormatter formats diff by marking each row as
 // ADDED/MODIFIED. It mutates the row and adds as a new column.
 func (f *Formatter) rowMark(diff digest.Differences) error {
 	_, _ = fmt.Fprintf(f.stderr, "Additions %d\n", len(diff.Additions))
 	_, _ = fmt.Fprintf(f.stderr, "Modifications %d\n", len(diff.Modifications))
 	_, _ = fmt.Fprintf(f.stderr, "Deletions %d\n", len(diff.Deletions))
+	if diff.Rekeyings != nil {
+		_, _ = fmt.Fprintf(f.stderr, "Rekeyings %d\n", len(diff.Rekeyings))
+	}
+	if diff.Moves != nil {
+		_, _ = fmt.Fprintf(f.stderr, "Moves %d\n", len(diff.Moves))
+	}
 	_, _ = fmt.Fprintf(f.stderr, "Rows:\n")
 
 	includes := f.ctx.GetIncludeColumnPositions()
 
 	additions := make([]string, 0, len(diff.Additions))
 	for _, addition := range diff.Additions {
-		additions = append(additions, includes.String(addition, f.ctx.separator))
+		additions = append(additions, includes.String(addition.Row, f.ctx.separator))
 	}
 
 	modifications := make([]string, 0, len(diff.Modifications))
@@ -168,7 +537,7 @@ func (f *Formatter) rowMark(diff digest.Differences) error {
 
 	deletions := make([]string, 0, len(diff.Deletions))
 	for _, deletion := range diff.Deletions {
-		deletions = append(deletions, includes.String(deletion, f.ctx.separator))
+		deletions = append(deletions, includes.String(deletion.Row, f.ctx.separator))
 	}
 
 	for _, added := range additions {
@@ -183,34 +552,83 @@ func (f *Formatter) rowMark(diff digest.Differences) error {
 		_, _ = fmt.Fprintf(f.stdout, "%s,%s\n", deleted, "DELETED")
 	}
 
+	for _, rekeying := range diff.Rekeyings {
+		_, _ = fmt.Fprintf(f.stdout, "%s,%s\n", includes.String(rekeying.Current, f.ctx.separator), "REKEYED")
+	}
+
+	for _, move := range diff.Moves {
+		_, _ = fmt.Fprintf(f.stdout, "%s,%s\n", includes.String(move.Row, f.ctx.separator), "MOVED")
+	}
+
 	return nil
 }
 
-// lineDiff is git-style line diff
+// lineDiff is git-style line diff.
+// With --line-numbers each row is preceded by a hunk header
+// with its lines in base and delta.
 func (f *Formatter) lineDiff(diff digest.Differences) error {
 	includes := f.ctx.GetIncludeColumnPositions()
 
 	blue := color.New(color.FgBlue).FprintfFunc()
 	red := color.New(color.FgRed).FprintfFunc()
 	green := color.New(color.FgGreen).FprintfFunc()
+	magenta := color.New(color.FgMagenta).FprintfFunc()
 
 	blue(f.stderr, "# Additions (%d)\n", len(diff.Additions))
 	for _, addition := range diff.Additions {
-		green(f.stdout, "+ %s\n", includes.String(addition, f.ctx.separator))
+		f.hunkHeader(digest.Position{}, addition.Position)
+		green(f.stdout, "+ %s\n", includes.String(addition.Row, f.ctx.separator))
 	}
 	blue(f.stderr, "# Modifications (%d)\n", len(diff.Modifications))
 	for _, modification := range diff.Modifications {
+		f.hunkHeader(modification.OriginalPosition, modification.CurrentPosition)
 		red(f.stdout, "- %s\n", includes.String(modification.Original, f.ctx.separator))
 		green(f.stdout, "+ %s\n", includes.String(modification.Current, f.ctx.separator))
 	}
 	blue(f.stderr, "# Deletions (%d)\n", len(diff.Deletions))
 	for _, deletion := range diff.Deletions {
-		red(f.stdout, "- %s\n", includes.String(deletion, f.ctx.separator))
+		f.hunkHeader(deletion.Position, digest.Position{})
+		red(f.stdout, "- %s\n", includes.String(deletion.Row, f.ctx.separator))
+	}
+	if diff.Rekeyings != nil {
+		blue(f.stderr, "# Rekeyings (%d)\n", len(diff.Rekeyings))
+	}
+	for _, rekeying := range diff.Rekeyings {
+		f.hunkHeader(rekeying.OriginalPosition, rekeying.CurrentPosition)
+		red(f.stdout, "- %s\n", includes.String(rekeying.Original, f.ctx.separator))
+		green(f.stdout, "+ %s\n", includes.String(rekeying.Current, f.ctx.separator))
+	}
+	if diff.Moves != nil {
+		blue(f.stderr, "# Moves (%d)\n", len(diff.Moves))
+	}
+	for _, move := range diff.Moves {
+		f.hunkHeader(move.OriginalPosition, move.CurrentPosition)
+		magenta(f.stdout, "~ %s (row %d -> %d)\n", includes.String(move.Row, f.ctx.separator), move.OriginalIndex, move.CurrentIndex)
 	}
 
 	return nil
 }
 
+// hunkHeader prints the lines of a row like "@@ -4 +5 @@" if --line-numbers is set.
+// Unknown positions are left out and nothing is printed if both are.
+func (f *Formatter) hunkHeader(base, delta digest.Position) {
+	if !f.ctx.lineNumbers {
+		return
+	}
+	lines := make([]string, 0, 2)
+	if base.Line > 0 {
+		lines = append(lines, fmt.Sprintf("-%d", base.Line))
+	}
+	if delta.Line > 0 {
+		lines = append(lines, fmt.Sprintf("+%d", delta.Line))
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	color.New(color.FgCyan).Fprintf(f.stdout, "@@ %s @@\n", strings.Join(lines, " "))
+}
+
 // wordDiff is git-style --word-diff
 func (f *Formatter) wordDiff(diff digest.Differences) error {
 	return f.wordLevelDiffs(diff, "[-%s-]", "{+%s+}")
@@ -232,29 +650,99 @@ func (f *Formatter) wordLevelDiffs(diff digest.Differences, deletionFormat, addi
 
 	_, _ = fmt.Fprintln(f.stderr, blue("# Additions (%d)", len(diff.Additions)))
 	for _, addition := range diff.Additions {
-		_, _ = fmt.Fprintln(f.stdout, green(additionFormat, includes.String(addition, f.ctx.separator)))
+		_, _ = fmt.Fprintln(f.stdout, green(additionFormat, includes.String(addition.Row, f.ctx.separator)))
+	}
+
+	// words marks the cells of current in the changed columns
+	words := func(original, current []string, columns []int) string {
+		result := make([]string, len(current))
+		copy(result, current)
+		for _, i := range columns {
+			if i < len(original) && i < len(current) {
+				result[i] = fmt.Sprintf("%s%s", red(deletionFormat, original[i]), green(additionFormat, current[i]))
+			}
+		}
+		return includes.String(result, f.ctx.separator)
 	}
 
 	_, _ = fmt.Fprintln(f.stderr, blue("# Modifications (%d)", len(diff.Modifications)))
 	for _, modification := range diff.Modifications {
-		result := make([]string, 0, len(modification.Current))
-		for i := 0; i < len(includes) || i < len(modification.Current); i++ {
-			if modification.Original[i] != modification.Current[i] {
-				removed := red(deletionFormat, modification.Original[i])
-				added := green(additionFormat, modification.Current[i])
-				result = append(result, fmt.Sprintf("%s%s", removed, added))
-			} else {
-				result = append(result, modification.Current[i])
-			}
+		columns := make([]int, 0, len(modification.Columns))
+		for _, column := range modification.Columns {
+			columns = append(columns, column.Index)
 		}
-		_, _ = fmt.Fprintln(f.stdout, includes.String(result, f.ctx.separator))
+		_, _ = fmt.Fprintln(f.stdout, words(modification.Original, modification.Current, columns))
 	}
 
 	_, _ = fmt.Fprintln(f.stderr, blue("# Deletions (%d)", len(diff.Deletions)))
 	for _, deletion := range diff.Deletions {
-		_, _ = fmt.Fprintln(f.stdout, red(deletionFormat, includes.String(deletion, f.ctx.separator)))
+		_, _ = fmt.Fprintln(f.stdout, red(deletionFormat, includes.String(deletion.Row, f.ctx.separator)))
+	}
+
+	if diff.Rekeyings != nil {
+		_, _ = fmt.Fprintln(f.stderr, blue("# Rekeyings (%d)", len(diff.Rekeyings)))
+	}
+	keys := f.ctx.GetPrimaryKeys()
+	for _, rekeying := range diff.Rekeyings {
+		var columns []int
+		for i, key := range keys {
+			if rekeying.OriginalKey[i] != rekeying.CurrentKey[i] {
+				columns = append(columns, key)
+			}
+		}
+		_, _ = fmt.Fprintln(f.stdout, words(rekeying.Original, rekeying.Current, columns))
+	}
+
+	if diff.Moves != nil {
+		_, _ = fmt.Fprintln(f.stderr, blue("# Moves (%d)", len(diff.Moves)))
+	}
+	for _, move := range diff.Moves {
+		_, _ = fmt.Fprintf(f.stdout, "%s (row %d -> %d)\n", includes.String(move.Row, f.ctx.separator), move.OriginalIndex, move.CurrentIndex)
 	}
 
 	return nil
 
 }
+
+// duplicates warns about repeated primary keys on stderr
+func (f *Formatter) duplicates(diff digest.Differences) {
+	if len(diff.Duplicates) == 0 {
+		return
+	}
+
+	yellow := color.New(color.FgYellow).FprintfFunc()
+
+	yellow(f.stderr, "# Duplicate keys (%d)\n", len(diff.Duplicates))
+	for _, d := range diff.Duplicates {
+		lines := make([]string, 0, len(d.Lines))
+		for _, line := range d.Lines {
+			lines = append(lines, strconv.Itoa(line))
+		}
+		yellow(f.stderr, "! %s in %s file on lines %s\n",
+			digest.Positions{}.String(d.Key, f.ctx.separator), d.File, strings.Join(lines, ", "))
+	}
+}
+
+// estimates prints the counts of the full diff estimated
+// from a --sample to stderr
+func (f *Formatter) estimates(diff digest.Differences) {
+	if f.ctx.sample <= 0 {
+		return
+	}
+
+	cyan := color.New(color.FgCyan).FprintfFunc()
+
+	cyan(f.stderr, "# Sample of %s of the keys. Estimates with 95%% confidence\n", strconv.FormatFloat(100*f.ctx.sample, 'f', -1, 64)+"%")
+	counts := []struct {
+		name  string
+		count int
+	}{
+		{"Additions", len(diff.Additions)},
+		{"Modifications", len(diff.Modifications)},
+		{"Deletions", len(diff.Deletions)},
+	}
+	for _, c := range counts {
+		estimate := digest.EstimateCount(c.count, f.ctx.sample)
+		cyan(f.stderr, "~ %s %.0f (%.0f - %.0f)\n", c.name, estimate.Count, estimate.Low, estimate.High)
+	}
+}
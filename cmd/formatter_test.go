@@ -11,9 +11,9 @@ import (
 
 func TestLegacyJSONFormat(t *testing.T) {
 	diff := digest.Differences{
-		Additions:     []digest.Addition{[]string{"additions"}},
+		Additions:     []digest.Addition{{Row: []string{"additions"}}},
 		Modifications: []digest.Modification{{Current: []string{"modification"}}},
-		Deletions:     []digest.Deletion{[]string{"deletions"}},
+		Deletions:     []digest.Deletion{{Row: []string{"deletions"}}},
 	}
 	expected := `{
   "Additions": [
@@ -39,23 +39,54 @@ func TestLegacyJSONFormat(t *testing.T) {
 
 func TestJSONFormat(t *testing.T) {
 	diff := digest.Differences{
-		Additions:     []digest.Addition{[]string{"additions"}},
-		Modifications: []digest.Modification{{Original: []string{"original"}, Current: []string{"modification"}}},
-		Deletions:     []digest.Deletion{[]string{"deletions"}},
+		Additions: []digest.Addition{{Row: []string{"additions"}, Position: digest.Position{Line: 3, Offset: 24}}},
+		Modifications: []digest.Modification{{
+			Original:         []string{"original"},
+			Current:          []string{"modification"},
+			OriginalPosition: digest.Position{Line: 2, Offset: 9},
+			CurrentPosition:  digest.Position{Line: 2, Offset: 10},
+			Columns:          []digest.ColumnChange{{Index: 0, Name: "value", Compared: true}},
+		}},
+		Deletions: []digest.Deletion{{Row: []string{"deletions"}, Position: digest.Position{Line: 4, Offset: 30}}},
 	}
 	expected := `{
   "Additions": [
     "additions"
   ],
+  "AdditionPositions": [
+    {
+      "Line": 3,
+      "Offset": 24
+    }
+  ],
   "Modifications": [
     {
       "Original": "original",
-      "Current": "modification"
+      "Current": "modification",
+      "OriginalLine": 2,
+      "OriginalOffset": 9,
+      "CurrentLine": 2,
+      "CurrentOffset": 10,
+      "Columns": [
+        {
+          "Index": 0,
+          "Name": "value",
+          "Compared": true
+        }
+      ]
     }
   ],
   "Deletions": [
     "deletions"
-  ]
+  ],
+  "DeletionPositions": [
+    {
+      "Line": 4,
+      "Offset": 30
+    }
+  ],
+  "Hash": "xxhash64",
+  "Verified": false
 }`
 
 	var stdout bytes.Buffer
@@ -67,11 +98,49 @@ func TestJSONFormat(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expected, stdout.String())
 }
+func TestJSONFormatWithDuplicates(t *testing.T) {
+	diff := digest.Differences{
+		Additions:     []digest.Addition{},
+		Modifications: []digest.Modification{},
+		Deletions:     []digest.Deletion{},
+		Duplicates:    []digest.Duplicate{{File: digest.Delta, Key: []string{"1", "a,b"}, Lines: []int{2, 5}}},
+	}
+	expected := `{
+  "Additions": [],
+  "AdditionPositions": [],
+  "Modifications": [],
+  "Deletions": [],
+  "DeletionPositions": [],
+  "Duplicates": [
+    {
+      "File": "delta",
+      "Key": "1,\"a,b\"",
+      "Lines": [
+        2,
+        5
+      ]
+    }
+  ],
+  "Hash": "xxhash64",
+  "Verified": true
+}`
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+
+	formatter := NewFormatter(&stdout, &stderr, Context{format: "json", verify: true})
+
+	err := formatter.Format(diff)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
 func TestRowMarkFormatter(t *testing.T) {
 	diff := digest.Differences{
-		Additions:     []digest.Addition{[]string{"additions"}},
+		Additions:     []digest.Addition{{Row: []string{"additions"}}},
 		Modifications: []digest.Modification{{Current: []string{"modification"}}},
-		Deletions:     []digest.Deletion{[]string{"deletions"}},
+		Deletions:     []digest.Deletion{{Row: []string{"deletions"}}},
 	}
 	expectedStdout := `additions,ADDED
 modification,MODIFIED
@@ -98,14 +167,14 @@ Rows:
 func TestLineDiff(t *testing.T) {
 	t.Run("should show line diff with comma by default", func(t *testing.T) {
 		diff := digest.Differences{
-			Additions: []digest.Addition{[]string{"additions"}},
+			Additions: []digest.Addition{{Row: []string{"additions"}}},
 			Modifications: []digest.Modification{
 				{
 					Original: []string{"original", "comma,separated,value"},
 					Current:  []string{"modification", "comma,separated,value-2"},
 				},
 			},
-			Deletions: []digest.Deletion{{"deletion", "this-row-was-deleted"}},
+			Deletions: []digest.Deletion{{Row: []string{"deletion", "this-row-was-deleted"}}},
 		}
 		expectedStdout := `+ additions
 - original,"comma,separated,value"
@@ -131,14 +200,14 @@ func TestLineDiff(t *testing.T) {
 
 	t.Run("should show line diff with custom separator", func(t *testing.T) {
 		diff := digest.Differences{
-			Additions: []digest.Addition{[]string{"additions"}},
+			Additions: []digest.Addition{{Row: []string{"additions"}}},
 			Modifications: []digest.Modification{
 				{
 					Original: []string{"original", "comma,separated,value"},
 					Current:  []string{"modification", "comma,separated,value-2"},
 				},
 			},
-			Deletions: []digest.Deletion{{"deletion", "this-row-was-deleted"}},
+			Deletions: []digest.Deletion{{Row: []string{"deletion", "this-row-was-deleted"}}},
 		}
 		expectedStdout := `+ additions
 - original|comma,separated,value
@@ -162,14 +231,190 @@ func TestLineDiff(t *testing.T) {
 		assert.Equal(t, expectedStderr, stderr.String())
 	})
 
+	t.Run("should show the lines of each row", func(t *testing.T) {
+		diff := digest.Differences{
+			Additions: []digest.Addition{{Row: []string{"additions"}, Position: digest.Position{Line: 3, Offset: 24}}},
+			Modifications: []digest.Modification{{
+				Original:         []string{"original"},
+				Current:          []string{"modification"},
+				OriginalPosition: digest.Position{Line: 2, Offset: 9},
+				CurrentPosition:  digest.Position{Line: 5, Offset: 40},
+			}},
+			Deletions: []digest.Deletion{{Row: []string{"deletion"}, Position: digest.Position{Line: 4, Offset: 30}}},
+		}
+		expectedStdout := `@@ +3 @@
++ additions
+@@ -2 +5 @@
+- original
++ modification
+@@ -4 @@
+- deletion
+`
+
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+
+		formatter := NewFormatter(&stdout, &stderr, Context{format: "diff", lineNumbers: true})
+
+		err := formatter.Format(diff)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStdout, stdout.String())
+	})
+}
+
+func TestDuplicatesWarning(t *testing.T) {
+	diff := digest.Differences{
+		Duplicates: []digest.Duplicate{
+			{File: digest.Base, Key: []string{"1"}, Lines: []int{2, 3}},
+			{File: digest.Delta, Key: []string{"4"}, Lines: []int{5, 8, 9}},
+		},
+	}
+	expectedStderr := `# Additions (0)
+# Modifications (0)
+# Deletions (0)
+# Duplicate keys (2)
+! 1 in base file on lines 2, 3
+! 4 in delta file on lines 5, 8, 9
+`
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+
+	formatter := NewFormatter(&stdout, &stderr, Context{format: "diff"})
+
+	err := formatter.Format(diff)
+
+	assert.NoError(t, err)
+	assert.Empty(t, stdout.String())
+	assert.Equal(t, expectedStderr, stderr.String())
+}
+
+func TestRekeyings(t *testing.T) {
+	diff := digest.Differences{
+		Rekeyings: []digest.Rekeying{{
+			OriginalKey:      []string{"2"},
+			CurrentKey:       []string{"12"},
+			Original:         []string{"2", "ryan"},
+			Current:          []string{"12", "ryan"},
+			OriginalPosition: digest.Position{Line: 2, Offset: 10},
+			CurrentPosition:  digest.Position{Line: 3, Offset: 20},
+		}},
+	}
+
+	t.Run("should show the original and current rows in line diff", func(t *testing.T) {
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+
+		err := NewFormatter(&stdout, &stderr, Context{format: "diff"}).Format(diff)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "- 2,ryan\n+ 12,ryan\n", stdout.String())
+		assert.Equal(t, "# Additions (0)\n# Modifications (0)\n# Deletions (0)\n# Rekeyings (1)\n", stderr.String())
+	})
+
+	t.Run("should mark rekeyed rows", func(t *testing.T) {
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+
+		err := NewFormatter(&stdout, &stderr, Context{format: "rowmark"}).Format(diff)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "12,ryan,REKEYED\n", stdout.String())
+		assert.Contains(t, stderr.String(), "Rekeyings 1\n")
+	})
+
+	t.Run("should list old and new keys in json", func(t *testing.T) {
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+
+		err := NewFormatter(&stdout, &stderr, Context{format: "json"}).Format(diff)
+
+		assert.NoError(t, err)
+		assert.Contains(t, stdout.String(), `"Rekeyings": [
+    {
+      "OriginalKey": "2",
+      "CurrentKey": "12",
+      "Original": "2,ryan",
+      "Current": "12,ryan",
+      "OriginalLine": 2,
+      "OriginalOffset": 10,
+      "CurrentLine": 3,
+      "CurrentOffset": 20
+    }
+  ]`)
+	})
+
+	t.Run("should word diff the keys", func(t *testing.T) {
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+
+		err := NewFormatter(&stdout, &stderr, Context{format: "word-diff"}).Format(diff)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "[-2-]{+12+},ryan\n", stdout.String())
+	})
+}
+
+func TestMoves(t *testing.T) {
+	diff := digest.Differences{
+		Moves: []digest.Move{{
+			Row:              []string{"4", "d"},
+			OriginalIndex:    5,
+			CurrentIndex:     2,
+			OriginalPosition: digest.Position{Line: 5, Offset: 21},
+			CurrentPosition:  digest.Position{Line: 2, Offset: 9},
+		}},
+	}
+
+	t.Run("should show the rows and where they moved in line diff", func(t *testing.T) {
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+
+		err := NewFormatter(&stdout, &stderr, Context{format: "diff"}).Format(diff)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "~ 4,d (row 5 -> 2)\n", stdout.String())
+		assert.Equal(t, "# Additions (0)\n# Modifications (0)\n# Deletions (0)\n# Moves (1)\n", stderr.String())
+	})
+
+	t.Run("should list both places in json", func(t *testing.T) {
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+
+		err := NewFormatter(&stdout, &stderr, Context{format: "json"}).Format(diff)
+
+		assert.NoError(t, err)
+		assert.Contains(t, stdout.String(), `"Moves": [
+    {
+      "Row": "4,d",
+      "OriginalIndex": 5,
+      "CurrentIndex": 2,
+      "OriginalLine": 5,
+      "OriginalOffset": 21,
+      "CurrentLine": 2,
+      "CurrentOffset": 9
+    }
+  ]`)
+	})
+
+	t.Run("should mark moved rows", func(t *testing.T) {
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+
+		err := NewFormatter(&stdout, &stderr, Context{format: "rowmark"}).Format(diff)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "4,d,MOVED\n", stdout.String())
+	})
 }
 
 func TestWordDiff(t *testing.T) {
 	t.Run("should cover single column happy path", func(t *testing.T) {
 		diff := digest.Differences{
-			Additions:     []digest.Addition{[]string{"additions"}},
-			Modifications: []digest.Modification{{Original: []string{"original"}, Current: []string{"modification"}}},
-			Deletions:     []digest.Deletion{{"deletions"}},
+			Additions:     []digest.Addition{{Row: []string{"additions"}}},
+			Modifications: []digest.Modification{{Original: []string{"original"}, Current: []string{"modification"}, Columns: []digest.ColumnChange{{Index: 0, Compared: true}}}},
+			Deletions:     []digest.Deletion{{Row: []string{"deletions"}}},
 		}
 		expectedStdout := `{+additions+}
 [-original-]{+modification+}
@@ -194,11 +439,15 @@ func TestWordDiff(t *testing.T) {
 
 	t.Run("should ouput only selective columns", func(t *testing.T) {
 		diff := digest.Differences{
-			Additions: []digest.Addition{[]string{"additions", "ignored-column"}},
+			Additions: []digest.Addition{{Row: []string{"additions", "ignored-column"}}},
 			Modifications: []digest.Modification{
-				{Original: []string{"original", "ignored-column"}, Current: []string{"modification", "ignored-column"}},
+				{
+					Original: []string{"original", "ignored-column"},
+					Current:  []string{"modification", "ignored-column"},
+					Columns:  []digest.ColumnChange{{Index: 0, Compared: true}},
+				},
 			},
-			Deletions: []digest.Deletion{{"deletions", "ignored-column"}},
+			Deletions: []digest.Deletion{{Row: []string{"deletions", "ignored-column"}}},
 		}
 		expectedStdout := `{+additions+}
 [-original-]{+modification+}
@@ -228,9 +477,9 @@ func TestWordDiff(t *testing.T) {
 
 func TestColorWords(t *testing.T) {
 	diff := digest.Differences{
-		Additions:     []digest.Addition{[]string{"additions"}},
-		Modifications: []digest.Modification{{Original: []string{"original"}, Current: []string{"modification"}}},
-		Deletions:     []digest.Deletion{{"deletions"}},
+		Additions:     []digest.Addition{{Row: []string{"additions"}}},
+		Modifications: []digest.Modification{{Original: []string{"original"}, Current: []string{"modification"}, Columns: []digest.ColumnChange{{Index: 0, Compared: true}}}},
+		Deletions:     []digest.Deletion{{Row: []string{"deletions"}}},
 	}
 	expectedStdout := `additions
 originalmodification
@@ -261,3 +510,169 @@ func TestWrongFormatter(t *testing.T) {
 
 	assert.Error(t, err)
 }
+
+func TestFormatStats(t *testing.T) {
+	stats := digest.Stats{
+		Additions:     1,
+		Modifications: 4,
+		Unchanged:     10,
+		Columns: []digest.ColumnStats{
+			{Index: 1, Name: "name", Compared: true, Changes: 1},
+			{Index: 2, Name: "role", Compared: false, Changes: 3, FromEmpty: 1, ToEmpty: 1},
+		},
+	}
+
+	t.Run("should print a table", func(t *testing.T) {
+		var stdout bytes.Buffer
+		formatter := NewFormatter(&stdout, &bytes.Buffer{}, Context{format: "diff"})
+
+		err := formatter.FormatStats(stats)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `Additions      1
+Modifications  4
+Deletions      0
+Unchanged      10
+
+  Column  Name  Compared  Changes  % of modifications  Empty to value  Value to empty
+       1  name       yes        1                25.0               0               0
+       2  role        no        3                75.0               1               1
+`, stdout.String())
+	})
+
+	t.Run("should print json", func(t *testing.T) {
+		var stdout bytes.Buffer
+		formatter := NewFormatter(&stdout, &bytes.Buffer{}, Context{format: "json", rekeys: true})
+
+		err := formatter.FormatStats(stats)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `{
+  "Additions": 1,
+  "Modifications": 4,
+  "Deletions": 0,
+  "Unchanged": 10,
+  "Duplicates": 0,
+  "Rekeyings": 0,
+  "Unknown": 0,
+  "Columns": [
+    {
+      "Index": 1,
+      "Name": "name",
+      "Compared": true,
+      "Changes": 1,
+      "FromEmpty": 0,
+      "ToEmpty": 0
+    },
+    {
+      "Index": 2,
+      "Name": "role",
+      "Compared": false,
+      "Changes": 3,
+      "FromEmpty": 1,
+      "ToEmpty": 1
+    }
+  ]
+}`, stdout.String())
+	})
+}
+
+func TestSampleEstimates(t *testing.T) {
+	diff := digest.Differences{
+		Additions: []digest.Addition{{Row: []string{"1", "one"}, Position: digest.Position{Line: 1}}},
+	}
+
+	t.Run("should print estimates to stderr", func(t *testing.T) {
+		expectedStderr := `# Additions (1)
+# Modifications (0)
+# Deletions (0)
+# Sample of 10% of the keys. Estimates with 95% confidence
+~ Additions 10 (1 - 29)
+~ Modifications 0 (0 - 28)
+~ Deletions 0 (0 - 28)
+`
+
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+
+		formatter := NewFormatter(&stdout, &stderr, Context{format: "diff", sample: 0.1})
+
+		err := formatter.Format(diff)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "+ 1,one\n", stdout.String())
+		assert.Equal(t, expectedStderr, stderr.String())
+	})
+
+	t.Run("should add estimates to json", func(t *testing.T) {
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+
+		formatter := NewFormatter(&stdout, &stderr, Context{format: "json", sample: 0.1})
+
+		err := formatter.Format(diff)
+
+		assert.NoError(t, err)
+		assert.Contains(t, stdout.String(), `"Sample": {
+    "Fraction": 0.1,
+    "Additions": {
+      "Count": 10,
+      "Low": 1,
+      "High": 28.59`)
+		assert.Contains(t, stdout.String(), `"Deletions": {
+      "Count": 0,
+      "Low": 0,
+      "High": 28
+    }`)
+		assert.Empty(t, stderr.String())
+	})
+}
+
+func TestFormatCounts(t *testing.T) {
+	diff := digest.CountDifferences{
+		Additions: []digest.RowCount{
+			{Row: []string{"d", "4"}, Count: 2, Position: digest.Position{Line: 5, Offset: 16}},
+		},
+		Deletions: []digest.RowCount{
+			{Row: []string{"a", "1"}, Count: 1, Position: digest.Position{Line: 1, Offset: 0}},
+		},
+	}
+
+	t.Run("should print the rows with their counts", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		formatter := NewFormatter(&stdout, &stderr, Context{format: "diff"})
+
+		err := formatter.FormatCounts(diff)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "+ d,4 (2 times)\n- a,1\n", stdout.String())
+		assert.Equal(t, "# Additions (2)\n# Deletions (1)\n", stderr.String())
+	})
+
+	t.Run("should print json", func(t *testing.T) {
+		var stdout bytes.Buffer
+		formatter := NewFormatter(&stdout, &bytes.Buffer{}, Context{format: "json"})
+
+		err := formatter.FormatCounts(diff)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `{
+  "Additions": [
+    {
+      "Row": "d,4",
+      "Count": 2,
+      "Line": 5,
+      "Offset": 16
+    }
+  ],
+  "Deletions": [
+    {
+      "Row": "a,1",
+      "Count": 1,
+      "Line": 1,
+      "Offset": 0
+    }
+  ]
+}`, stdout.String())
+	})
+}
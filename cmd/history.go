@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+)
+
+var historyFormat string
+
+// historyCmd follows the row of each key across a series of csv files
+var historyCmd = &cobra.Command{
+	Use:          "history <csv> <csv>...",
+	SilenceUsage: true,
+	Short:        "Follow the row of each key across a series of csv files",
+	Long: `Diffs each csv file against the one before and lists for each key
+whose row changed when it was first seen, modified and deleted. Keys with
+the same row in all files since they were first seen are left out.
+
+Files are diffed in the order given, each digested once. Each file is
+labelled by the date in its name, like 2024-01-31 or 20240131, or else
+by its name.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runeSeparator, err := parseSeparator(separator)
+		if err != nil {
+			return err
+		}
+		if historyFormat != lineDiff && historyFormat != jsonFormat {
+			return fmt.Errorf("unknown --format %q. Available (%s|%s)", historyFormat, lineDiff, jsonFormat)
+		}
+
+		if err := runHistory(afero.NewOsFs(), args, runeSeparator, os.Stdout); err != nil {
+			return &exitError{code: errorExitCode, err: err}
+		}
+		return nil
+	},
+}
+
+// runHistory writes the timelines of the keys of filenames to outputStream
+func runHistory(fs afero.Fs, filenames []string, separator rune, outputStream io.Writer) error {
+	header, err := getHeader(fs, filenames[0], separator, lazyQuotes)
+	if err != nil {
+		return fmt.Errorf("error in %s: %v", filenames[0], err)
+	}
+	inBounds := func(element int) bool { return element < len(header) }
+	if !assertAll(primaryKeyPositions, inBounds) {
+		return fmt.Errorf("--primary-key positions are out of bounds")
+	}
+	if !assertAll(valueColumnPositions, inBounds) {
+		return fmt.Errorf("--columns positions are out of bounds")
+	}
+	if !hasHeader {
+		header = nil
+	}
+
+	configs := make([]digest.Config, 0, len(filenames))
+	for _, filename := range filenames {
+		file, err := fs.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		configs = append(configs, digest.Config{
+			Reader:     file,
+			Key:        primaryKeyPositions,
+			Value:      valueColumnPositions,
+			Separator:  separator,
+			LazyQuotes: lazyQuotes,
+			SkipHeader: hasHeader,
+		})
+	}
+	configs[0].Header = header
+
+	history, err := digest.History(configs)
+	if err != nil {
+		return err
+	}
+
+	labels := make([]string, 0, len(filenames))
+	for _, filename := range filenames {
+		labels = append(labels, fileLabel(filename))
+	}
+	if historyFormat == jsonFormat {
+		return historyJSON(history, labels, separator, outputStream)
+	}
+	historyTimelines(history, labels, separator, outputStream)
+	return nil
+}
+
+var fileDate = regexp.MustCompile(`(\d{4})-?(\d{2})-?(\d{2})`)
+
+// fileLabel is the date in the name of filename, or else its name
+func fileLabel(filename string) string {
+	name := filepath.Base(filename)
+	if date := fileDate.FindStringSubmatch(name); date != nil {
+		return strings.Join(date[1:], "-")
+	}
+	return name
+}
+
+// eventNames name the events of a timeline
+var eventNames = map[digest.EventType]string{
+	digest.Seen:     "seen",
+	digest.Modified: "modified",
+	digest.Deleted:  "deleted",
+}
+
+// eventName names the event at i of timeline. The first
+// event a key is seen is told apart from seeing it again.
+func eventName(timeline digest.Timeline, i int) string {
+	if timeline.Events[i].Type == digest.Seen {
+		if i == 0 {
+			return "first seen"
+		}
+		return "seen again"
+	}
+	return eventNames[timeline.Events[i].Type]
+}
+
+// historyTimelines writes a block per key with a line per event
+func historyTimelines(history []digest.Timeline, labels []string, separator rune, outputStream io.Writer) {
+	blue := color.New(color.FgBlue).FprintfFunc()
+	colors := map[digest.EventType]func(io.Writer, string, ...interface{}){
+		digest.Seen:     color.New(color.FgGreen).FprintfFunc(),
+		digest.Modified: color.New(color.FgYellow).FprintfFunc(),
+		digest.Deleted:  color.New(color.FgRed).FprintfFunc(),
+	}
+
+	width := 0
+	for _, label := range labels {
+		if len(label) > width {
+			width = len(label)
+		}
+	}
+
+	for _, timeline := range history {
+		blue(outputStream, "# %s\n", digest.Positions{}.String(timeline.Key, separator))
+		for i, e := range timeline.Events {
+			line := fmt.Sprintf("%-*s  %-10s  %s", width, labels[e.File], eventName(timeline, i), digest.Positions{}.String(e.Row, separator))
+			if len(e.Columns) > 0 {
+				line += "  (" + columnList(e.Columns) + ")"
+			}
+			colors[e.Type](outputStream, "%s\n", line)
+		}
+	}
+}
+
+// columnList lists changed columns by their name or else by their index
+func columnList(columns []digest.ColumnChange) string {
+	names := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if c.Name != "" {
+			names = append(names, c.Name)
+		} else {
+			names = append(names, strconv.Itoa(c.Index))
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// historyJSON writes the timelines as a JSON Object
+func historyJSON(history []digest.Timeline, labels []string, separator rune, outputStream io.Writer) error {
+	type column struct {
+		Index    int
+		Name     string `json:",omitempty"`
+		Compared bool
+	}
+
+	type event struct {
+		Event   string
+		File    string
+		Row     string
+		Columns []column `json:",omitempty"`
+	}
+
+	type timeline struct {
+		Key    string
+		Events []event
+	}
+
+	timelines := make([]timeline, 0, len(history))
+	for _, t := range history {
+		events := make([]event, 0, len(t.Events))
+		for i, e := range t.Events {
+			var columns []column
+			for _, c := range e.Columns {
+				columns = append(columns, column{Index: c.Index, Name: c.Name, Compared: c.Compared})
+			}
+			events = append(events, event{
+				Event:   eventName(t, i),
+				File:    labels[e.File],
+				Row:     digest.Positions{}.String(e.Row, separator),
+				Columns: columns,
+			})
+		}
+		timelines = append(timelines, timeline{Key: digest.Positions{}.String(t.Key, separator), Events: events})
+	}
+
+	data, err := json.MarshalIndent(struct {
+		Files     []string
+		Timelines []timeline
+	}{labels, timelines}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error when serializing history with JSON formatter: %v", err)
+	}
+
+	_, err = outputStream.Write(data)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+
+	historyCmd.Flags().StringVarP(&historyFormat, "format", "o", lineDiff, fmt.Sprintf("Available (%s|%s)", lineDiff, jsonFormat))
+	addHeaderFlag(historyCmd, "The first line of the files names the columns and is not diffed. Names the changed columns of modifications")
+	addPrimaryKeyFlag(historyCmd)
+	addColumnsFlags(historyCmd, false)
+	addCsvFlags(historyCmd)
+}
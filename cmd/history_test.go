@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunHistory(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	files := map[string]string{
+		"/snapshots/users-2024-01-01.csv": "id,name,age\n1,tom,2\n2,ryan,20\n",
+		"/snapshots/users-20240102.csv":   "id,name,age\n1,tom,2\n2,ryan,21\n3,emin,40\n",
+		"/snapshots/users-final.csv":      "id,name,age\n2,ryan,21\n3,emin,40\n",
+	}
+	for filename, content := range files {
+		assert.NoError(t, afero.WriteFile(fs, filename, []byte(content), os.ModePerm))
+	}
+	filenames := []string{"/snapshots/users-2024-01-01.csv", "/snapshots/users-20240102.csv", "/snapshots/users-final.csv"}
+
+	t.Run("should list the events of each changed key", func(t *testing.T) {
+		hasHeader = true
+		defer func() { hasHeader = false }()
+		expected := `# 1
+2024-01-01       first seen  1,tom,2
+users-final.csv  deleted     1,tom,2
+# 2
+2024-01-01       first seen  2,ryan,20
+2024-01-02       modified    2,ryan,21  (age)
+# 3
+2024-01-02       first seen  3,emin,40
+`
+
+		outStream := &bytes.Buffer{}
+		err := runHistory(fs, filenames, ',', outStream)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, outStream.String())
+	})
+
+	t.Run("should write the timelines as json", func(t *testing.T) {
+		historyFormat = jsonFormat
+		defer func() { historyFormat = lineDiff }()
+		expected := `{
+  "Files": [
+    "2024-01-01",
+    "2024-01-02",
+    "users-final.csv"
+  ],
+  "Timelines": [
+    {
+      "Key": "1",
+      "Events": [
+        {
+          "Event": "first seen",
+          "File": "2024-01-01",
+          "Row": "1,tom,2"
+        },
+        {
+          "Event": "deleted",
+          "File": "users-final.csv",
+          "Row": "1,tom,2"
+        }
+      ]
+    },
+    {
+      "Key": "2",
+      "Events": [
+        {
+          "Event": "first seen",
+          "File": "2024-01-01",
+          "Row": "2,ryan,20"
+        },
+        {
+          "Event": "modified",
+          "File": "2024-01-02",
+          "Row": "2,ryan,21",
+          "Columns": [
+            {
+              "Index": 2,
+              "Compared": true
+            }
+          ]
+        }
+      ]
+    },
+    {
+      "Key": "3",
+      "Events": [
+        {
+          "Event": "first seen",
+          "File": "2024-01-02",
+          "Row": "3,emin,40"
+        }
+      ]
+    }
+  ]
+}`
+
+		outStream := &bytes.Buffer{}
+		err := runHistory(fs, filenames, ',', outStream)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, outStream.String())
+	})
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+)
+
+// limitExitCode is the exit code of a diff with more changes than its limits allow
+const limitExitCode = 3
+
+// limit bounds the number of additions, modifications or deletions
+// by a number of rows or, if percent is set, by a percentage of the rows in base
+type limit struct {
+	changes string
+	value   string
+	max     float64
+	percent bool
+}
+
+// parseLimit parses the --max-<changes> flag like 1000 or 5%.
+// It is nil if value is empty.
+func parseLimit(changes, value string) (*limit, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	number, percent := strings.TrimSpace(value), false
+	if strings.HasSuffix(number, "%") {
+		number, percent = strings.TrimSpace(strings.TrimSuffix(number, "%")), true
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 || (!percent && n != float64(int(n))) {
+		return nil, fmt.Errorf("unable to use %q as --max-%s. Eg: 1000, 5%%", value, changes)
+	}
+
+	return &limit{changes: changes, value: strings.TrimSpace(value), max: n, percent: percent}, nil
+}
+
+// exceeded tells if count changes exceed l in a base of baseRows rows
+func (l limit) exceeded(count, baseRows int) bool {
+	if l.percent {
+		return float64(count) > l.max/100*float64(baseRows)
+	}
+	return float64(count) > l.max
+}
+
+// violation describes how count changes exceed l
+func (l limit) violation(count, baseRows int) string {
+	if !l.percent {
+		return fmt.Sprintf("%s %d > --max-%s %s", l.changes, count, l.changes, l.value)
+	}
+
+	share := 100.0
+	if baseRows > 0 {
+		share = 100 * float64(count) / float64(baseRows)
+	}
+	return fmt.Sprintf("%s %d (%.1f%% of %d rows in base) > --max-%s %s",
+		l.changes, count, share, baseRows, l.changes, l.value)
+}
+
+// changeCounts are the counts of changes the limits bound
+// and the number of rows in base their percentages are of
+type changeCounts struct {
+	additions     int
+	modifications int
+	deletions     int
+	baseRows      int
+}
+
+// baseRowCounter counts the rows read from base, duplicates included,
+// as the diff reports its progress
+type baseRowCounter struct {
+	rows int64
+}
+
+// hook returns a digest.Config.Progress counting the rows of base
+// and passing the progress on to next, if set
+func (c *baseRowCounter) hook(next func(digest.Progress)) func(digest.Progress) {
+	return func(p digest.Progress) {
+		c.rows = p.Base.Rows
+		if next != nil {
+			next(p)
+		}
+	}
+}
+
+// countsOf counts the changes of diff in a base of baseRows rows
+func countsOf(diff digest.Differences, baseRows int64) changeCounts {
+	return changeCounts{
+		additions:     len(diff.Additions),
+		modifications: len(diff.Modifications),
+		deletions:     len(diff.Deletions),
+		baseRows:      int(baseRows),
+	}
+}
+
+// countsOfStats is countsOf for the counts of --stats
+func countsOfStats(stats digest.Stats, baseRows int64) changeCounts {
+	return changeCounts{
+		additions:     stats.Additions,
+		modifications: stats.Modifications,
+		deletions:     stats.Deletions,
+		baseRows:      int(baseRows),
+	}
+}
+
+func (c changeCounts) of(changes string) int {
+	switch changes {
+	case "additions":
+		return c.additions
+	case "modifications":
+		return c.modifications
+	default:
+		return c.deletions
+	}
+}
+
+// checkLimits returns an exitError with limitExitCode listing
+// the limits that counts exceed. It is nil if none is exceeded.
+func checkLimits(limits []limit, counts changeCounts) error {
+	var violations []string
+	for _, l := range limits {
+		if count := counts.of(l.changes); l.exceeded(count, counts.baseRows) {
+			violations = append(violations, l.violation(count, counts.baseRows))
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return &exitError{
+		code: limitExitCode,
+		err:  fmt.Errorf("limits exceeded\n! %s", strings.Join(violations, "\n! ")),
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	testCases := []struct {
+		in  string
+		out *limit
+	}{
+		{in: "", out: nil},
+		{in: "1000", out: &limit{changes: "additions", value: "1000", max: 1000}},
+		{in: " 0 ", out: &limit{changes: "additions", value: "0", max: 0}},
+		{in: "5%", out: &limit{changes: "additions", value: "5%", max: 5, percent: true}},
+		{in: "0.5 %", out: &limit{changes: "additions", value: "0.5 %", max: 0.5, percent: true}},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.in, func(t *testing.T) {
+			l, err := parseLimit("additions", tt.in)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.out, l)
+		})
+	}
+
+	for _, invalid := range []string{"%", "-1", "1.5", "lots"} {
+		t.Run(invalid, func(t *testing.T) {
+			_, err := parseLimit("additions", invalid)
+			assert.EqualError(t, err, `unable to use "`+invalid+`" as --max-additions. Eg: 1000, 5%`)
+		})
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	limits := []limit{
+		{changes: "additions", value: "10", max: 10},
+		{changes: "deletions", value: "5%", max: 5, percent: true},
+	}
+
+	t.Run("should pass changes within the limits", func(t *testing.T) {
+		counts := changeCounts{additions: 10, modifications: 500, deletions: 5, baseRows: 100}
+
+		assert.NoError(t, checkLimits(limits, counts))
+	})
+
+	t.Run("should list the limits exceeded", func(t *testing.T) {
+		counts := changeCounts{additions: 11, deletions: 6, baseRows: 100}
+
+		err := checkLimits(limits, counts)
+
+		assert.Equal(t, limitExitCode, err.(*exitError).code)
+		assert.EqualError(t, err, `limits exceeded
+! additions 11 > --max-additions 10
+! deletions 6 (6.0% of 100 rows in base) > --max-deletions 5%`)
+	})
+
+	t.Run("should exceed a percentage of an empty base", func(t *testing.T) {
+		counts := changeCounts{deletions: 1}
+
+		assert.EqualError(t, checkLimits(limits, counts), `limits exceeded
+! deletions 1 (100.0% of 0 rows in base) > --max-deletions 5%`)
+	})
+}
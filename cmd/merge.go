@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+)
+
+const (
+	// mergeConflictExitCode is the exit code of a merge with conflicts
+	mergeConflictExitCode = 1
+	// mergeErrorExitCode is the exit code of a merge that failed
+	mergeErrorExitCode = 2
+)
+
+var (
+	mergeOutput    string
+	mergeConflicts string
+)
+
+// mergeCmd merges the rows two csv files changed since a common base
+var mergeCmd = &cobra.Command{
+	Use:          "merge <base-csv> <ours-csv> <theirs-csv>",
+	SilenceUsage: true,
+	Short:        "Merge the rows two csv files changed since a common base",
+	Long: `Merges the rows ours and theirs changed since base by primary key.
+A row changed on one side takes the change and a row changed on both sides
+takes the changes to each cell. Cells changed differently are marked like
+"<<<<<<< ours ======= theirs >>>>>>>" and reported on stderr.
+
+Exits with 0 if the merge is clean, 1 if there are conflicts and 2 if it fails.
+It can be used as a git merge driver:
+
+  # .gitattributes
+  *.csv merge=csvdiff
+
+  # .git/config
+  [merge "csvdiff"]
+      driver = csvdiff merge %O %A %B -o %A`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(3)(cmd, args); err != nil {
+			return &exitError{code: mergeErrorExitCode, err: err}
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runeSeparator, err := parseSeparator(separator)
+		if err != nil {
+			return &exitError{code: mergeErrorExitCode, err: err}
+		}
+
+		conflicts, err := runMerge(afero.NewOsFs(), args[0], args[1], args[2], runeSeparator, os.Stdout, os.Stderr)
+		if err != nil {
+			return &exitError{code: mergeErrorExitCode, err: err}
+		}
+		if conflicts > 0 {
+			return &exitError{code: mergeConflictExitCode}
+		}
+
+		return nil
+	},
+}
+
+// runMerge merges the files to mergeOutput or to outputStream and returns
+// the number of conflicts. Conflicts are reported on errorStream and
+// written to mergeConflicts as JSON if it is set.
+func runMerge(fs afero.Fs, baseFilename, oursFilename, theirsFilename string, separator rune, outputStream, errorStream io.Writer) (int, error) {
+	configs := make([]digest.Config, 0, 3)
+	for _, filename := range []string{baseFilename, oursFilename, theirsFilename} {
+		file, err := fs.Open(filename)
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+
+		configs = append(configs, digest.Config{
+			Reader:     file,
+			Key:        primaryKeyPositions,
+			Separator:  separator,
+			LazyQuotes: lazyQuotes,
+		})
+	}
+
+	var merged bytes.Buffer
+	conflicts, err := digest.Merge(configs[0], configs[1], configs[2], &merged)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := writeOutput(fs, mergeOutput, &merged, outputStream); err != nil {
+		return 0, err
+	}
+
+	reportConflicts(conflicts, separator, errorStream)
+	if mergeConflicts != "" {
+		if err := writeConflicts(fs, mergeConflicts, conflicts, separator); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(conflicts), nil
+}
+
+// reportConflicts lists the conflicts on errorStream
+func reportConflicts(conflicts []digest.Conflict, separator rune, errorStream io.Writer) {
+	if len(conflicts) == 0 {
+		return
+	}
+
+	yellow := color.New(color.FgYellow).FprintfFunc()
+	yellow(errorStream, "# Conflicts (%d)\n", len(conflicts))
+	for _, c := range conflicts {
+		key := digest.Positions{}.String(c.Key, separator)
+		switch {
+		case c.Ours == nil:
+			yellow(errorStream, "! %s on row %d: deleted in ours, modified in theirs\n", key, c.Row)
+		case c.Theirs == nil:
+			yellow(errorStream, "! %s on row %d: modified in ours, deleted in theirs\n", key, c.Row)
+		default:
+			columns := make([]string, 0, len(c.Columns))
+			for _, column := range c.Columns {
+				columns = append(columns, fmt.Sprint(column))
+			}
+			yellow(errorStream, "! %s on row %d: changed differently in columns %s\n", key, c.Row, strings.Join(columns, ", "))
+		}
+	}
+}
+
+// writeConflicts writes the conflicts to filename as JSON
+func writeConflicts(fs afero.Fs, filename string, conflicts []digest.Conflict, separator rune) error {
+	type conflict struct {
+		Key     string
+		Row     int
+		Columns []int
+		Base    *string
+		Ours    *string
+		Theirs  *string
+	}
+
+	row := func(cells []string) *string {
+		if cells == nil {
+			return nil
+		}
+		s := digest.Positions{}.String(cells, separator)
+		return &s
+	}
+
+	report := make([]conflict, 0, len(conflicts))
+	for _, c := range conflicts {
+		columns := c.Columns
+		if columns == nil {
+			columns = []int{}
+		}
+		report = append(report, conflict{
+			Key:     digest.Positions{}.String(c.Key, separator),
+			Row:     c.Row,
+			Columns: columns,
+			Base:    row(c.Base),
+			Ours:    row(c.Ours),
+			Theirs:  row(c.Theirs),
+		})
+	}
+
+	data, err := json.MarshalIndent(struct{ Conflicts []conflict }{report}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error when serializing conflicts: %v", err)
+	}
+
+	return afero.WriteFile(fs, filename, data, 0644)
+}
+
+func init() {
+	rootCmd.AddCommand(mergeCmd)
+
+	mergeCmd.Flags().StringVarP(&mergeOutput, "output", "o", "", "File to write the merged csv to. Default is stdout")
+	mergeCmd.Flags().StringVar(&mergeConflicts, "conflicts", "", "File to write the conflicts to as JSON")
+	addPrimaryKeyFlag(mergeCmd)
+	addCsvFlags(mergeCmd)
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunMerge(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/base.csv", []byte("id,name,role\n1,tom,developer\n2,ryan,qa\n"), os.ModePerm))
+	assert.NoError(t, afero.WriteFile(fs, "/ours.csv", []byte("id,name,role\n1,tom,lead\n2,ryan,qa\n"), os.ModePerm))
+	assert.NoError(t, afero.WriteFile(fs, "/theirs.csv", []byte("id,name,role\n1,tom,manager\n2,ryan,qa-lead\n"), os.ModePerm))
+
+	mergeOutput, mergeConflicts = "/ours.csv", "/conflicts.json"
+	defer func() { mergeOutput, mergeConflicts = "", "" }()
+
+	var stdout, stderr bytes.Buffer
+	conflicts, err := runMerge(fs, "/base.csv", "/ours.csv", "/theirs.csv", ',', &stdout, &stderr)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, conflicts)
+	assert.Empty(t, stdout.String())
+	assert.Equal(t, "# Conflicts (1)\n! 1 on row 2: changed differently in columns 2\n", stderr.String())
+
+	merged, err := afero.ReadFile(fs, "/ours.csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "id,name,role\n1,tom,<<<<<<< lead ======= manager >>>>>>>\n2,ryan,qa-lead\n", string(merged))
+
+	report, err := afero.ReadFile(fs, "/conflicts.json")
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "Conflicts": [
+    {
+      "Key": "1",
+      "Row": 2,
+      "Columns": [
+        2
+      ],
+      "Base": "1,tom,developer",
+      "Ours": "1,tom,lead",
+      "Theirs": "1,tom,manager"
+    }
+  ]
+}`, string(report))
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+)
+
+// progressInterval is the least time between two redraws of a progress bar
+const progressInterval = 100 * time.Millisecond
+
+// progressBarWidth is the number of cells in a progress bar
+const progressBarWidth = 20
+
+// progressBar draws the progress of a diff on a single line of a terminal
+// along with the throughput and an ETA based on the size of both files.
+// A nil progressBar draws nothing.
+type progressBar struct {
+	out   io.Writer
+	total int64
+	start time.Time
+	drawn time.Time
+	phase digest.Phase
+	now   func() time.Time
+}
+
+func newProgressBar(out io.Writer, total int64) *progressBar {
+	return &progressBar{out: out, total: total, start: time.Now(), phase: -1, now: time.Now}
+}
+
+// hook returns the function to pass as digest.Config.Progress
+func (b *progressBar) hook() func(digest.Progress) {
+	if b == nil {
+		return nil
+	}
+	return b.update
+}
+
+// update redraws the bar on a change of phase
+// or if it was not redrawn for progressInterval
+func (b *progressBar) update(p digest.Progress) {
+	now := b.now()
+	if p.Phase == b.phase && now.Sub(b.drawn) < progressInterval {
+		return
+	}
+	b.phase, b.drawn = p.Phase, now
+
+	_, _ = fmt.Fprintf(b.out, "\r%s\x1b[K", b.line(p, now))
+}
+
+func (b *progressBar) line(p digest.Progress, now time.Time) string {
+	read := p.Base.Bytes + p.Delta.Bytes
+	parts := []string{fmt.Sprintf("%-18s", p.Phase)}
+
+	if b.total > 0 {
+		fraction := float64(read) / float64(b.total)
+		if fraction > 1 {
+			fraction = 1
+		}
+		filled := int(fraction * progressBarWidth)
+		bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+		parts = append(parts, fmt.Sprintf("[%s] %3.0f%%", bar, fraction*100), fmt.Sprintf("%s/%s", formatBytes(read), formatBytes(b.total)))
+	} else {
+		parts = append(parts, formatBytes(read))
+	}
+
+	if elapsed := now.Sub(b.start).Seconds(); elapsed > 0 {
+		throughput := float64(read) / elapsed
+		parts = append(parts, formatBytes(int64(throughput))+"/s")
+		if b.total > read && throughput > 0 {
+			eta := time.Duration(float64(b.total-read) / throughput * float64(time.Second))
+			parts = append(parts, "ETA "+eta.Round(time.Second).String())
+		}
+	}
+
+	parts = append(parts, fmt.Sprintf("%d rows", p.Base.Rows+p.Delta.Rows))
+	return strings.Join(parts, "  ")
+}
+
+// clear removes the bar so that the output starts on a clean line
+func (b *progressBar) clear() {
+	if b == nil {
+		return
+	}
+	_, _ = fmt.Fprint(b.out, "\r\x1b[K")
+}
+
+// formatBytes formats a byte count with binary units Eg: 1.5 GB
+func formatBytes(bytes int64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
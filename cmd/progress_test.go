@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressBar(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newBar := func(total int64) (*progressBar, *bytes.Buffer, *time.Time) {
+		var out bytes.Buffer
+		now := start
+		bar := &progressBar{out: &out, total: total, start: start, phase: -1, now: func() time.Time { return now }}
+		return bar, &out, &now
+	}
+
+	t.Run("should show throughput and ETA", func(t *testing.T) {
+		bar, out, now := newBar(4 << 30)
+		*now = start.Add(10 * time.Second)
+
+		bar.update(digest.Progress{
+			Phase: digest.ReadingDelta,
+			Base:  digest.FileProgress{Rows: 1000, Bytes: 2 << 30},
+			Delta: digest.FileProgress{Rows: 500, Bytes: 1 << 30},
+		})
+
+		assert.Equal(t, "\rreading delta       [===============     ]  75%  3.0 GB/4.0 GB  307.2 MB/s  ETA 3s  1500 rows\x1b[K", out.String())
+	})
+
+	t.Run("should show bytes read without a total", func(t *testing.T) {
+		bar, out, now := newBar(0)
+		*now = start.Add(time.Second)
+
+		bar.update(digest.Progress{Phase: digest.Merging, Base: digest.FileProgress{Rows: 10, Bytes: 2048}})
+
+		assert.Equal(t, "\rmerging             2.0 KB  2.0 KB/s  10 rows\x1b[K", out.String())
+	})
+
+	t.Run("should redraw only on a new phase or after an interval", func(t *testing.T) {
+		bar, out, now := newBar(100)
+
+		bar.update(digest.Progress{Phase: digest.ReadingBase})
+		drawn := out.Len()
+		*now = start.Add(progressInterval / 2)
+		bar.update(digest.Progress{Phase: digest.ReadingBase, Base: digest.FileProgress{Rows: 1, Bytes: 10}})
+		assert.Equal(t, drawn, out.Len())
+
+		bar.update(digest.Progress{Phase: digest.ReadingDelta, Base: digest.FileProgress{Rows: 1, Bytes: 10}})
+		assert.Greater(t, out.Len(), drawn)
+	})
+
+	t.Run("should draw nothing when disabled", func(t *testing.T) {
+		var bar *progressBar
+
+		assert.Nil(t, bar.hook())
+		bar.clear()
+	})
+}
@@ -21,20 +21,35 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
 
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/afero"
 
 	"github.com/aswinkarthik/csvdiff/pkg/digest"
 	"github.com/spf13/cobra"
 )
 
+const (
+	// diffExitCode is the exit code of files that differ
+	diffExitCode = 1
+	// errorExitCode is the exit code of a command that failed
+	errorExitCode = 2
+	// interruptExitCode is the exit code of a diff stopped by Ctrl-C,
+	// as shells report a command killed by SIGINT
+	interruptExitCode = 130
+)
+
 var (
 	timed bool
 )
@@ -44,9 +59,14 @@ var rootCmd = &cobra.Command{
 	Use:           "csvdiff <base-csv> <delta-csv>",
 	SilenceUsage:  true,
 	SilenceErrors: true,
+	Args:          cobra.ArbitraryArgs,
 	Short:         "A diff tool for database tables dumped as csv files",
 	Long: `Differentiates two csv files and finds out the additions and modifications.
-Most suitable for csv files created from database tables`,
+Most suitable for csv files created from database tables.
+
+Exits with 0 if the files are the same, 1 if they differ and 2 if the diff fails.
+Exits with 3 if the changes exceed --max-additions, --max-modifications or --max-deletions.
+Exits with 130 if the diff is stopped by Ctrl-C.`,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		// validate args
 		if len(args) != 2 {
@@ -66,6 +86,40 @@ Most suitable for csv files created from database tables`,
 		if err != nil {
 			return err
 		}
+		duplicatePolicy, err := parseDuplicatePolicy(duplicates)
+		if err != nil {
+			return err
+		}
+		maxMemoryBytes, err := parseSize(maxMemory)
+		if err != nil {
+			return err
+		}
+		order, err := parseOrder(sortBy)
+		if err != nil {
+			return err
+		}
+		sortedFiles, collation, err := parseSorted(sorted)
+		if err != nil {
+			return err
+		}
+		sampleFraction, err := parseFraction(sample)
+		if err != nil {
+			return err
+		}
+		var limits []limit
+		for _, l := range []struct{ changes, value string }{
+			{"additions", maxAdditions},
+			{"modifications", maxModifications},
+			{"deletions", maxDeletions},
+		} {
+			parsed, err := parseLimit(l.changes, l.value)
+			if err != nil {
+				return err
+			}
+			if parsed != nil {
+				limits = append(limits, *parsed)
+			}
+		}
 		ctx, err := NewContext(
 			fs,
 			primaryKeyPositions,
@@ -83,29 +137,143 @@ Most suitable for csv files created from database tables`,
 			return err
 		}
 		defer ctx.Close()
+		ctx.duplicates = duplicatePolicy
+		ctx.verify = verify
+		ctx.maxMemory = maxMemoryBytes
+		ctx.spillDir = spillDir
+		ctx.sorted = sortedFiles
+		ctx.collation = collation
+		ctx.order = order
+		ctx.index = index
+		ctx.rekeys = rekeys
+		ctx.reorders = reorders
+		ctx.hasHeader = hasHeader
+		ctx.stats = stats
+		ctx.sample = sampleFraction
+		ctx.quiet = quiet
+		ctx.limits = limits
+		ctx.keyless = keyless
+		ctx.lineNumbers = lineNumbers
+		if quiet && stats {
+			return fmt.Errorf("--quiet prints nothing. It cannot be used with --stats")
+		}
+		if len(limits) > 0 && (quiet || sampleFraction > 0) {
+			return fmt.Errorf("--max-additions, --max-modifications and --max-deletions count all changes. They cannot be used with --quiet or --sample")
+		}
+		if stats && cmd.Flags().Changed("format") && !strings.EqualFold(format, jsonFormat) {
+			return fmt.Errorf("--stats prints a table, or json with --format json")
+		}
+		if sampleFraction > 0 && (stats || rekeys || reorders) {
+			return fmt.Errorf("--sample cannot be used with --stats, --detect-rekeys or --detect-moves")
+		}
+		if sampleFraction > 0 && strings.EqualFold(format, patchFormat) {
+			return fmt.Errorf("a patch needs all rows. It cannot be made from a --sample")
+		}
+		if keyless && cmd.Flags().Changed("primary-key") {
+			return fmt.Errorf("--keyless has no primary key. Rows are told apart by --columns")
+		}
+		if keyless && (sortedFiles || maxMemoryBytes > 0 || index || ctx.baseSnapshot) {
+			return fmt.Errorf("--keyless counts rows in memory. It cannot be used with --sorted, --max-memory, --index or a snapshot base-file")
+		}
+		if keyless && (stats || rekeys || reorders || sampleFraction > 0 || len(limits) > 0) {
+			return fmt.Errorf("--keyless only adds and deletes rows. It cannot be used with --stats, --detect-rekeys, --detect-moves, --sample or --max-additions, --max-modifications and --max-deletions")
+		}
+		if keyless && !strings.EqualFold(format, lineDiff) && !strings.EqualFold(format, jsonFormat) {
+			return fmt.Errorf("--keyless prints a diff, or json with --format json")
+		}
+		if ctx.baseSnapshot && (sortedFiles || maxMemoryBytes > 0 || index) {
+			return fmt.Errorf("a snapshot base-file is always diffed in memory. It cannot be used with --sorted, --max-memory or --index")
+		}
+		if index && (sortedFiles || maxMemoryBytes > 0) {
+			return fmt.Errorf("--index cannot be used with --sorted or --max-memory")
+		}
+		if !quiet && (isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd())) {
+			ctx.progress = newProgressBar(os.Stderr, ctx.totalSize())
+		}
+
+		// Ctrl-C stops the diff. A second one kills csvdiff as usual.
+		interrupt, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		go func() {
+			<-interrupt.Done()
+			stop()
+		}()
 
-		return runContext(ctx, os.Stdout, os.Stderr)
+		differs, err := runContext(interrupt, ctx, os.Stdout, os.Stderr)
+		if err != nil {
+			return err
+		}
+		if differs {
+			return &exitError{code: diffExitCode}
+		}
+		return nil
 	},
 }
 
-func runContext(ctx *Context, outputStream, errorStream io.Writer) error {
+// runContext diffs the files of ctx until the diff is done or interrupt is.
+// It tells if the files differ and fails with limitExitCode once the diff
+// is written if the changes exceed the limits of ctx, or with
+// interruptExitCode if interrupt is done first.
+func runContext(interrupt context.Context, ctx *Context, outputStream, errorStream io.Writer) (bool, error) {
 	baseConfig, err := ctx.BaseDigestConfig()
 	if err != nil {
-		return fmt.Errorf("error opening base-file %s: %v", ctx.baseFilename, err)
+		return false, fmt.Errorf("error opening base-file %s: %v", ctx.baseFilename, err)
 	}
 	deltaConfig, err := ctx.DeltaDigestConfig()
 	if err != nil {
-		return fmt.Errorf("error opening delta-file %s: %v", ctx.deltaFilename, err)
+		return false, fmt.Errorf("error opening delta-file %s: %v", ctx.deltaFilename, err)
+	}
+
+	if ctx.keyless {
+		diff, err := digest.DiffCountsContext(interrupt, baseConfig, deltaConfig)
+		ctx.progress.clear()
+		if interrupt.Err() != nil {
+			return false, errInterrupted
+		}
+		if err != nil || ctx.quiet {
+			return diff.Changed(), err
+		}
+		return diff.Changed(), NewFormatter(outputStream, errorStream, *ctx).FormatCounts(diff)
+	}
+
+	if ctx.quiet {
+		differs, err := digest.DiffersContext(interrupt, baseConfig, deltaConfig)
+		ctx.progress.clear()
+		if interrupt.Err() != nil {
+			return false, errInterrupted
+		}
+		return differs, err
+	}
+
+	if ctx.stats {
+		stats, err := digest.DiffStatsContext(interrupt, baseConfig, deltaConfig)
+		ctx.progress.clear()
+		if interrupt.Err() != nil {
+			return false, errInterrupted
+		}
+		if err != nil {
+			return false, err
+		}
+		if err := NewFormatter(outputStream, errorStream, *ctx).FormatStats(stats); err != nil {
+			return false, err
+		}
+		return stats.Changed(), checkLimits(ctx.limits, countsOfStats(stats, ctx.baseRows.rows))
 	}
-	defer ctx.Close()
 
-	diff, err := digest.Diff(baseConfig, deltaConfig)
+	diff, err := digest.DiffContext(interrupt, baseConfig, deltaConfig)
+	ctx.progress.clear()
 
+	if interrupt.Err() != nil {
+		return false, errInterrupted
+	}
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return NewFormatter(outputStream, errorStream, *ctx).Format(diff)
+	if err := NewFormatter(outputStream, errorStream, *ctx).Format(diff); err != nil {
+		return false, err
+	}
+	return diff.Changed(), checkLimits(ctx.limits, countsOf(diff, ctx.baseRows.rows))
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -113,10 +281,33 @@ func runContext(ctx *Context, outputStream, errorStream io.Writer) error {
 func Execute() {
 	rootCmd.Version = Version()
 	if err := rootCmd.Execute(); err != nil {
+		if exit, ok := err.(*exitError); ok {
+			if exit.err != nil {
+				_, _ = fmt.Fprint(os.Stderr, color.RedString("csvdiff: %v\n", exit.err))
+			}
+			os.Exit(exit.code)
+		}
 		_, _ = fmt.Fprint(os.Stderr, color.RedString("csvdiff: command failed - %v\n\n", err))
 		_ = rootCmd.Help()
-		os.Exit(1)
+		os.Exit(errorExitCode)
+	}
+}
+
+// errInterrupted ends a diff stopped by Ctrl-C
+var errInterrupted = &exitError{code: interruptExitCode, err: errors.New("interrupted")}
+
+// exitError ends csvdiff with code instead of errorExitCode
+// without printing the help. err is printed if set.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit code %d", e.code)
 	}
+	return e.err.Error()
 }
 
 var (
@@ -127,6 +318,24 @@ var (
 	format                     string
 	separator                  string
 	lazyQuotes                 bool
+	duplicates                 string
+	verify                     bool
+	maxMemory                  string
+	spillDir                   string
+	sorted                     string
+	sortBy                     string
+	index                      bool
+	rekeys                     bool
+	reorders                   bool
+	hasHeader                  bool
+	stats                      bool
+	sample                     string
+	quiet                      bool
+	maxAdditions               string
+	maxModifications           string
+	maxDeletions               string
+	keyless                    bool
+	lineNumbers                bool
 )
 
 func init() {
@@ -141,6 +350,25 @@ func init() {
 
 	rootCmd.Flags().BoolVarP(&timed, "time", "", false, "Measure time")
 	rootCmd.Flags().BoolVar(&lazyQuotes, "lazyquotes", false, "allow unescaped quotes")
+	rootCmd.Flags().BoolVar(&verify, "verify", false, "Confirm every hash match by comparing the cells")
+	rootCmd.Flags().StringVar(&sorted, "sorted", "", fmt.Sprintf("Both files are sorted by primary key (%s). Diffs them in lockstep in constant memory", strings.Join(allSorts, "|")))
+	rootCmd.Flags().Lookup("sorted").NoOptDefVal = textSort
+	rootCmd.Flags().BoolVar(&index, "index", false, "Hold only hashes and offsets of base rows in memory and read rows again for output")
+	rootCmd.Flags().BoolVar(&hasHeader, "header", false, "The first line of the files names the columns and is not diffed. Names the changed columns of modifications")
+	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Print nothing and stop at the first difference. Only the exit code tells if the files differ")
+	rootCmd.Flags().BoolVar(&lineNumbers, "line-numbers", false, "Precede each row of the diff format by its lines in base and delta like @@ -4 +5 @@")
+	rootCmd.Flags().BoolVar(&stats, "stats", false, "Print how often each column changed instead of the rows. A table, or json with --format json")
+	rootCmd.Flags().BoolVar(&reorders, "detect-moves", false, "Report rows found in both files whose place among the other rows changed")
+	rootCmd.Flags().BoolVar(&keyless, "keyless", false, "The rows have no primary key. Diffs the files as multisets of rows, or of --columns, with their counts")
+	rootCmd.Flags().BoolVar(&rekeys, "detect-rekeys", false, "Report deleted and added rows with the same values under a new primary key as rekeyed")
+	rootCmd.Flags().StringVar(&maxMemory, "max-memory", "", "Bound memory by spilling to disk Eg: 512MB, 2GB. Default is all in memory")
+	rootCmd.Flags().StringVar(&spillDir, "spill-dir", "", "Directory for the files spilled by --max-memory. Default is the system temp directory")
+	rootCmd.Flags().StringVar(&sample, "sample", "", "Diff only the keys in a fraction of the hash space Eg: 1%, 0.05. Estimates the counts of the full diff")
+	rootCmd.Flags().StringVar(&maxAdditions, "max-additions", "", "Exit with 3 if more rows are added Eg: 1000, or 5% of the rows in base")
+	rootCmd.Flags().StringVar(&maxModifications, "max-modifications", "", "Exit with 3 if more rows are modified Eg: 1000, or 5% of the rows in base")
+	rootCmd.Flags().StringVar(&maxDeletions, "max-deletions", "", "Exit with 3 if more rows are deleted Eg: 1000, or 5% of the rows in base")
+	rootCmd.Flags().StringVar(&sortBy, "sort", fileOrder, fmt.Sprintf("Order of the rows in the output (%s)", strings.Join(allOrders, "|")))
+	rootCmd.Flags().StringVar(&duplicates, "duplicates", warnOnDuplicates, fmt.Sprintf("What to do with repeated primary keys (%s)", strings.Join(allDuplicatePolicies, "|")))
 }
 
 func timeTrack(start time.Time, name string) {
@@ -148,6 +376,120 @@ func timeTrack(start time.Time, name string) {
 	_, _ = fmt.Fprintln(os.Stderr, fmt.Sprintf("%s took %s", name, elapsed))
 }
 
+const (
+	warnOnDuplicates     = "warn"
+	failOnDuplicates     = "fail"
+	multisetOnDuplicates = "multiset"
+)
+
+var allDuplicatePolicies = []string{warnOnDuplicates, failOnDuplicates, multisetOnDuplicates}
+
+func parseDuplicatePolicy(policy string) (digest.DuplicatePolicy, error) {
+	switch strings.ToLower(policy) {
+	case warnOnDuplicates:
+		return digest.DuplicateWarn, nil
+	case failOnDuplicates:
+		return digest.DuplicateFail, nil
+	case multisetOnDuplicates:
+		return digest.DuplicateMultiset, nil
+	default:
+		return digest.DuplicateWarn, fmt.Errorf("unknown --duplicates policy %q. Available (%s)", policy, strings.Join(allDuplicatePolicies, "|"))
+	}
+}
+
+const (
+	textSort    = "text"
+	numericSort = "numeric"
+)
+
+var allSorts = []string{textSort, numericSort}
+
+// parseSorted tells if the files are sorted and how their keys compare.
+// An empty value means the files are not sorted.
+func parseSorted(sorted string) (bool, digest.Collation, error) {
+	switch strings.ToLower(sorted) {
+	case "":
+		return false, digest.TextCollation, nil
+	case textSort:
+		return true, digest.TextCollation, nil
+	case numericSort:
+		return true, digest.NumericCollation, nil
+	default:
+		return false, digest.TextCollation, fmt.Errorf("unknown --sorted order %q. Available (%s)", sorted, strings.Join(allSorts, "|"))
+	}
+}
+
+const (
+	fileOrder = "file"
+	keyOrder  = "key"
+)
+
+var allOrders = []string{fileOrder, keyOrder}
+
+func parseOrder(order string) (digest.Order, error) {
+	switch strings.ToLower(order) {
+	case fileOrder:
+		return digest.FileOrder, nil
+	case keyOrder:
+		return digest.KeyOrder, nil
+	default:
+		return digest.FileOrder, fmt.Errorf("unknown --sort order %q. Available (%s)", order, strings.Join(allOrders, "|"))
+	}
+}
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30}, {"G", 1 << 30},
+	{"MB", 1 << 20}, {"M", 1 << 20},
+	{"KB", 1 << 10}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize parses sizes like 512MB or 2G into bytes.
+// An empty size is zero.
+func parseSize(size string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+
+	number, unit := strings.ToUpper(strings.TrimSpace(size)), int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(number, u.suffix) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, u.suffix)), u.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("unable to use %q as a size. Eg: 512MB, 2GB", size)
+	}
+
+	return n * unit, nil
+}
+
+// parseFraction parses fractions like 0.05 or 5%.
+// An empty fraction is zero, meaning all rows.
+func parseFraction(fraction string) (float64, error) {
+	if fraction == "" {
+		return 0, nil
+	}
+
+	number, scale := strings.TrimSpace(fraction), 1.0
+	if strings.HasSuffix(number, "%") {
+		number, scale = strings.TrimSpace(strings.TrimSuffix(number, "%")), 100
+	}
+
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil || f <= 0 || f/scale > 1 {
+		return 0, fmt.Errorf("unable to use %q as a sample. Eg: 1%%, 0.05", fraction)
+	}
+
+	return f / scale, nil
+}
+
 func parseSeparator(sep string) (rune, error) {
 	if strings.HasPrefix(sep, "\\t") {
 		return '\t', nil
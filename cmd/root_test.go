@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"os"
 	"testing"
 
@@ -46,28 +47,281 @@ func TestRunContext(t *testing.T) {
 			false,
 		)
 		assert.NoError(t, err)
+		ctx.hasHeader = true
 
 		outStream := &bytes.Buffer{}
 		errStream := &bytes.Buffer{}
 
-		err = runContext(ctx, outStream, errStream)
+		differs, err := runContext(context.Background(), ctx, outStream, errStream)
 		expected := `{
   "Additions": [
     "1,caprio,3"
   ],
+  "AdditionPositions": [
+    {
+      "Line": 3,
+      "Offset": 35
+    }
+  ],
   "Modifications": [
     {
       "Original": "2,ryan,20",
-      "Current": "2,ryan,23"
+      "Current": "2,ryan,23",
+      "OriginalLine": 3,
+      "OriginalOffset": 35,
+      "CurrentLine": 4,
+      "CurrentOffset": 56,
+      "Columns": [
+        {
+          "Index": 2,
+          "Name": "age",
+          "Compared": true
+        }
+      ]
     }
   ],
   "Deletions": [
     "4,emin,40"
-  ]
+  ],
+  "DeletionPositions": [
+    {
+      "Line": 4,
+      "Offset": 48
+    }
+  ],
+  "Hash": "xxhash64",
+  "Verified": false
 }`
 
 		assert.NoError(t, err)
+		assert.True(t, differs)
 		assert.Equal(t, expected, outStream.String())
 
 	})
+
+	t.Run("should stop when interrupted", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		assert.NoError(t, afero.WriteFile(fs, "/base.csv", []byte("1,one\n"), os.ModePerm))
+		assert.NoError(t, afero.WriteFile(fs, "/delta.csv", []byte("1,two\n"), os.ModePerm))
+
+		ctx, err := NewContext(fs, digest.Positions{0}, nil, nil, nil, "diff", "/base.csv", "/delta.csv", ',', false)
+		assert.NoError(t, err)
+
+		interrupt, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		outStream := &bytes.Buffer{}
+		_, err = runContext(interrupt, ctx, outStream, &bytes.Buffer{})
+
+		assert.EqualError(t, err, "interrupted")
+		assert.Equal(t, interruptExitCode, err.(*exitError).code)
+		assert.Empty(t, outStream.String())
+	})
+
+	t.Run("should tell if the files differ", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			delta   string
+			quiet   bool
+			stats   bool
+			differs bool
+		}{
+			{name: "same files", delta: "1,one\n2,two\n", differs: false},
+			{name: "changed files", delta: "1,one\n2,zwei\n", differs: true},
+			{name: "quietly same files", delta: "1,one\n2,two\n", quiet: true, differs: false},
+			{name: "quietly changed files", delta: "1,one\n3,three\n", quiet: true, differs: true},
+			{name: "counting same files", delta: "1,one\n2,two\n", stats: true, differs: false},
+			{name: "counting changed files", delta: "2,two\n", stats: true, differs: true},
+		}
+		for _, tt := range testCases {
+			t.Run(tt.name, func(t *testing.T) {
+				fs := afero.NewMemMapFs()
+				assert.NoError(t, afero.WriteFile(fs, "/base.csv", []byte("1,one\n2,two\n"), os.ModePerm))
+				assert.NoError(t, afero.WriteFile(fs, "/delta.csv", []byte(tt.delta), os.ModePerm))
+
+				ctx, err := NewContext(fs, digest.Positions{0}, nil, nil, nil, "diff", "/base.csv", "/delta.csv", ',', false)
+				assert.NoError(t, err)
+				ctx.quiet = tt.quiet
+				ctx.stats = tt.stats
+
+				outStream := &bytes.Buffer{}
+				errStream := &bytes.Buffer{}
+				differs, err := runContext(context.Background(), ctx, outStream, errStream)
+
+				assert.NoError(t, err)
+				assert.Equal(t, tt.differs, differs)
+				if tt.quiet {
+					assert.Empty(t, outStream.String())
+					assert.Empty(t, errStream.String())
+				}
+			})
+		}
+	})
+}
+
+func TestRunContextLimits(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/base.csv", []byte("1,one\n2,two\n3,three\n4,four\n"), os.ModePerm))
+	assert.NoError(t, afero.WriteFile(fs, "/delta.csv", []byte("1,one\n2,zwei\n5,five\n"), os.ModePerm))
+
+	testCases := []struct {
+		name     string
+		limits   []string
+		stats    bool
+		exceeded string
+	}{
+		{name: "within the limits", limits: []string{"1", "1", "50%"}},
+		{name: "over a count", limits: []string{"0", "1", "50%"}, exceeded: "! additions 1 > --max-additions 0"},
+		{name: "over a percentage", limits: []string{"1", "1", "25%"}, exceeded: "! deletions 2 (50.0% of 4 rows in base) > --max-deletions 25%"},
+		{name: "over a percentage of the stats", limits: []string{"1", "1", "25%"}, stats: true, exceeded: "! deletions 2 (50.0% of 4 rows in base) > --max-deletions 25%"},
+	}
+	for _, tt := range testCases {
+		t.Run("should check the changes "+tt.name, func(t *testing.T) {
+			ctx, err := NewContext(fs, digest.Positions{0}, nil, nil, nil, "diff", "/base.csv", "/delta.csv", ',', false)
+			assert.NoError(t, err)
+			ctx.stats = tt.stats
+			for i, changes := range []string{"additions", "modifications", "deletions"} {
+				l, err := parseLimit(changes, tt.limits[i])
+				assert.NoError(t, err)
+				ctx.limits = append(ctx.limits, *l)
+			}
+
+			outStream := &bytes.Buffer{}
+			differs, err := runContext(context.Background(), ctx, outStream, &bytes.Buffer{})
+
+			assert.True(t, differs)
+			assert.NotEmpty(t, outStream.String())
+			if tt.exceeded == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, "limits exceeded\n"+tt.exceeded)
+		})
+	}
+
+	t.Run("should count the duplicate rows of base", func(t *testing.T) {
+		assert.NoError(t, afero.WriteFile(fs, "/duplicates.csv", []byte("1,one\n1,uno\n2,two\n3,three\n"), os.ModePerm))
+		assert.NoError(t, afero.WriteFile(fs, "/one.csv", []byte("1,one\n"), os.ModePerm))
+		ctx, err := NewContext(fs, digest.Positions{0}, nil, nil, nil, "diff", "/duplicates.csv", "/one.csv", ',', false)
+		assert.NoError(t, err)
+		l, err := parseLimit("deletions", "25%")
+		assert.NoError(t, err)
+		ctx.limits = append(ctx.limits, *l)
+
+		_, err = runContext(context.Background(), ctx, &bytes.Buffer{}, &bytes.Buffer{})
+
+		assert.EqualError(t, err, "limits exceeded\n! deletions 2 (50.0% of 4 rows in base) > --max-deletions 25%")
+	})
+}
+
+func TestRunContextKeyless(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/base.csv", []byte("a,1\na,1\nb,2\n"), os.ModePerm))
+	assert.NoError(t, afero.WriteFile(fs, "/delta.csv", []byte("b,2\na,1\na,1\na,1\n"), os.ModePerm))
+
+	t.Run("should diff the rows as multisets", func(t *testing.T) {
+		ctx, err := NewContext(fs, digest.Positions{0}, nil, nil, nil, "diff", "/base.csv", "/delta.csv", ',', false)
+		assert.NoError(t, err)
+		ctx.keyless = true
+
+		outStream := &bytes.Buffer{}
+		differs, err := runContext(context.Background(), ctx, outStream, &bytes.Buffer{})
+
+		assert.NoError(t, err)
+		assert.True(t, differs)
+		assert.Equal(t, "+ a,1\n", outStream.String())
+	})
+
+	t.Run("should print nothing if quiet", func(t *testing.T) {
+		ctx, err := NewContext(fs, digest.Positions{0}, nil, nil, nil, "diff", "/base.csv", "/base.csv", ',', false)
+		assert.NoError(t, err)
+		ctx.keyless = true
+		ctx.quiet = true
+
+		outStream := &bytes.Buffer{}
+		differs, err := runContext(context.Background(), ctx, outStream, &bytes.Buffer{})
+
+		assert.NoError(t, err)
+		assert.False(t, differs)
+		assert.Empty(t, outStream.String())
+	})
+}
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		in  string
+		out int64
+	}{
+		{in: "", out: 0},
+		{in: "1024", out: 1024},
+		{in: "512MB", out: 512 << 20},
+		{in: "2g", out: 2 << 30},
+		{in: "64 KB", out: 64 << 10},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.in, func(t *testing.T) {
+			size, err := parseSize(tt.in)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.out, size)
+		})
+	}
+
+	for _, invalid := range []string{"MB", "-1GB", "lots"} {
+		t.Run(invalid, func(t *testing.T) {
+			_, err := parseSize(invalid)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseFraction(t *testing.T) {
+	testCases := []struct {
+		in  string
+		out float64
+	}{
+		{in: "", out: 0},
+		{in: "0.05", out: 0.05},
+		{in: "10%", out: 0.1},
+		{in: "0.5 %", out: 0.005},
+		{in: "1", out: 1},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.in, func(t *testing.T) {
+			fraction, err := parseFraction(tt.in)
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.out, fraction, 1e-12)
+		})
+	}
+
+	for _, invalid := range []string{"%", "0", "-1%", "150%", "1.5", "some"} {
+		t.Run(invalid, func(t *testing.T) {
+			_, err := parseFraction(invalid)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseSorted(t *testing.T) {
+	testCases := []struct {
+		in        string
+		sorted    bool
+		collation digest.Collation
+	}{
+		{in: "", sorted: false, collation: digest.TextCollation},
+		{in: "text", sorted: true, collation: digest.TextCollation},
+		{in: "Numeric", sorted: true, collation: digest.NumericCollation},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.in, func(t *testing.T) {
+			sorted, collation, err := parseSorted(tt.in)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.sorted, sorted)
+			assert.Equal(t, tt.collation, collation)
+		})
+	}
+
+	t.Run("should fail on an unknown order", func(t *testing.T) {
+		_, _, err := parseSorted("natural")
+		assert.EqualError(t, err, `unknown --sorted order "natural". Available (text|numeric)`)
+	})
 }
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+)
+
+var selectOutput string
+
+// selectCommands write the rows a set operation picks from two csv files
+var selectCommands = []struct {
+	name  string
+	op    digest.SetOperation
+	short string
+}{
+	{"only-base", digest.OnlyBase, "Write the rows of base-csv whose primary key is not in delta-csv"},
+	{"only-delta", digest.OnlyDelta, "Write the rows of delta-csv whose primary key is not in base-csv"},
+	{"intersect", digest.Intersect, "Write the rows of base-csv whose primary key is in delta-csv as well"},
+	{"changed", digest.Changed, "Write the rows of delta-csv whose primary key is in base-csv with other values"},
+}
+
+// newSelectCmd creates the command writing the rows op picks
+func newSelectCmd(name string, op digest.SetOperation, short string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          name + " <base-csv> <delta-csv>",
+		SilenceUsage: true,
+		Short:        short,
+		Long: short + `. Rows are written as csv
+as they are in their file and in the order of that file. Pass --header if
+the files have a header, which is written before the rows.
+Primary keys must be unique in both files.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runeSeparator, err := parseSeparator(separator)
+			if err != nil {
+				return err
+			}
+
+			if err := runSelect(afero.NewOsFs(), args[0], args[1], op, runeSeparator, os.Stdout); err != nil {
+				return &exitError{code: errorExitCode, err: err}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&selectOutput, "output", "o", "", "File to write the rows to. Default is stdout")
+	addHeaderFlag(cmd, "The first line of the files is a header to write first")
+	addPrimaryKeyFlag(cmd)
+	addColumnsFlags(cmd, false)
+	addCsvFlags(cmd)
+
+	return cmd
+}
+
+// runSelect writes the rows op picks from baseFilename and
+// deltaFilename to selectOutput or to outputStream
+func runSelect(fs afero.Fs, baseFilename, deltaFilename string, op digest.SetOperation, separator rune, outputStream io.Writer) error {
+	baseHeader, err := getHeader(fs, baseFilename, separator, lazyQuotes)
+	if err != nil {
+		return fmt.Errorf("error in base-file: %v", err)
+	}
+	deltaHeader, err := getHeader(fs, deltaFilename, separator, lazyQuotes)
+	if err != nil {
+		return fmt.Errorf("error in delta-file: %v", err)
+	}
+	if len(baseHeader) != len(deltaHeader) {
+		return fmt.Errorf("base-file and delta-file columns count do not match")
+	}
+	inBounds := func(element int) bool { return element < len(baseHeader) }
+	if !assertAll(primaryKeyPositions, inBounds) {
+		return fmt.Errorf("--primary-key positions are out of bounds")
+	}
+	if !assertAll(valueColumnPositions, inBounds) {
+		return fmt.Errorf("--columns positions are out of bounds")
+	}
+
+	baseFile, err := fs.Open(baseFilename)
+	if err != nil {
+		return err
+	}
+	defer baseFile.Close()
+	deltaFile, err := fs.Open(deltaFilename)
+	if err != nil {
+		return err
+	}
+	defer deltaFile.Close()
+
+	config := func(file afero.File) digest.Config {
+		return digest.Config{
+			Reader:     file,
+			Key:        primaryKeyPositions,
+			Value:      valueColumnPositions,
+			Separator:  separator,
+			LazyQuotes: lazyQuotes,
+			SkipHeader: hasHeader,
+		}
+	}
+
+	var selected bytes.Buffer
+	if err := digest.Select(config(baseFile), config(deltaFile), op, &selected); err != nil {
+		return err
+	}
+
+	return writeOutput(fs, selectOutput, &selected, outputStream)
+}
+
+func init() {
+	for _, c := range selectCommands {
+		rootCmd.AddCommand(newSelectCmd(c.name, c.op, c.short))
+	}
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSelect(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/base.csv", []byte("id;name\n1;tom\n2;ryan\n"), os.ModePerm))
+	assert.NoError(t, afero.WriteFile(fs, "/delta.csv", []byte("key;name\n2;ryan\n3;emin\n"), os.ModePerm))
+	hasHeader = true
+	defer func() { hasHeader = false }()
+
+	t.Run("should write the header of the file the rows come from", func(t *testing.T) {
+		outStream := &bytes.Buffer{}
+		err := runSelect(fs, "/base.csv", "/delta.csv", digest.OnlyDelta, ';', outStream)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "key;name\n3;emin\n", outStream.String())
+	})
+
+	t.Run("should write the rows in place", func(t *testing.T) {
+		selectOutput = "/base.csv"
+		defer func() { selectOutput = "" }()
+
+		err := runSelect(fs, "/base.csv", "/delta.csv", digest.Intersect, ';', &bytes.Buffer{})
+
+		assert.NoError(t, err)
+		selected, err := afero.ReadFile(fs, "/base.csv")
+		assert.NoError(t, err)
+		assert.Equal(t, "id;name\n2;ryan\n", string(selected))
+	})
+
+	t.Run("should check the primary key positions", func(t *testing.T) {
+		primaryKeyPositions = []int{2}
+		defer func() { primaryKeyPositions = []int{0} }()
+
+		err := runSelect(fs, "/base.csv", "/delta.csv", digest.OnlyBase, ';', &bytes.Buffer{})
+
+		assert.EqualError(t, err, "--primary-key positions are out of bounds")
+	})
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+)
+
+var (
+	snapshotOutput string
+	snapshotRows   bool
+)
+
+// snapshotCmd saves the digests of a csv file to diff against later
+var snapshotCmd = &cobra.Command{
+	Use:          "snapshot <csv> -o <snapshot>",
+	SilenceUsage: true,
+	Short:        "Save the digests of a csv file to diff against later",
+	Long: `Saves the digests of a csv file. The snapshot can be passed as the base-csv
+of a later diff in place of the file. It must be diffed with the same
+--primary-key, --columns, --separator, --lazyquotes and --header it was taken with.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runeSeparator, err := parseSeparator(separator)
+		if err != nil {
+			return err
+		}
+
+		return runSnapshot(afero.NewOsFs(), args[0], snapshotOutput, runeSeparator)
+	},
+}
+
+// runSnapshot writes the snapshot of filename to output
+func runSnapshot(fs afero.Fs, filename, output string, separator rune) error {
+	recordCount, err := getColumnsCount(fs, filename, separator, lazyQuotes)
+	if err != nil {
+		return fmt.Errorf("error in csv: %v", err)
+	}
+
+	if len(ignoreValueColumnPositions) > 0 && len(valueColumnPositions) > 0 {
+		return fmt.Errorf("only one of --columns or --ignore-columns")
+	}
+	values := digest.Positions(valueColumnPositions)
+	if len(ignoreValueColumnPositions) > 0 {
+		values = inferValueColumns(recordCount, ignoreValueColumnPositions)
+	}
+
+	inBounds := func(element int) bool {
+		return element < recordCount
+	}
+	if !assertAll(primaryKeyPositions, inBounds) {
+		return fmt.Errorf("--primary-key positions are out of bounds")
+	}
+	if !assertAll(values, inBounds) {
+		return fmt.Errorf("--columns positions are out of bounds")
+	}
+
+	file, err := fs.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	snapshot, err := fs.Create(output)
+	if err != nil {
+		return err
+	}
+
+	config := digest.Config{
+		Reader:     file,
+		Key:        primaryKeyPositions,
+		Value:      values,
+		Separator:  separator,
+		LazyQuotes: lazyQuotes,
+		SkipHeader: hasHeader,
+	}
+	if err := digest.WriteSnapshot(snapshot, config, snapshotRows); err != nil {
+		_ = snapshot.Close()
+		return err
+	}
+
+	return snapshot.Close()
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+
+	snapshotCmd.Flags().StringVarP(&snapshotOutput, "output", "o", "", "File to write the snapshot to")
+	snapshotCmd.Flags().BoolVar(&snapshotRows, "rows", false, "Store the rows too, so that modifications show the original row")
+	addPrimaryKeyFlag(snapshotCmd)
+	addColumnsFlags(snapshotCmd, true)
+	addCsvFlags(snapshotCmd)
+	addHeaderFlag(snapshotCmd, "The first line of the file names the columns and is not stored")
+	_ = snapshotCmd.MarkFlagRequired("output")
+}
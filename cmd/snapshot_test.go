@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSnapshot(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/base.csv", []byte(`id,name,age
+1,tom,2
+2,ryan,20
+`), os.ModePerm))
+	assert.NoError(t, afero.WriteFile(fs, "/delta.csv", []byte(`id,name,age
+1,tom,2
+2,ryan,23
+`), os.ModePerm))
+
+	t.Run("should diff a file against its snapshot", func(t *testing.T) {
+		assert.NoError(t, runSnapshot(fs, "/base.csv", "/base.digest", ','))
+
+		ctx, err := NewContext(fs, digest.Positions{0}, nil, nil, nil, "rowmark", "/base.digest", "/delta.csv", ',', false)
+		assert.NoError(t, err)
+		assert.True(t, ctx.baseSnapshot)
+
+		outStream := &bytes.Buffer{}
+		errStream := &bytes.Buffer{}
+		differs, err := runContext(context.Background(), ctx, outStream, errStream)
+
+		assert.NoError(t, err)
+		assert.True(t, differs)
+		assert.Equal(t, "2,ryan,23,MODIFIED\n", outStream.String())
+	})
+
+	t.Run("should leave the header out of the snapshot with --header", func(t *testing.T) {
+		assert.NoError(t, afero.WriteFile(fs, "/renamed.csv", []byte(`key,name,age
+1,tom,2
+2,ryan,23
+`), os.ModePerm))
+		hasHeader = true
+		defer func() { hasHeader = false }()
+		assert.NoError(t, runSnapshot(fs, "/base.csv", "/header.digest", ','))
+
+		ctx, err := NewContext(fs, digest.Positions{0}, nil, nil, nil, "rowmark", "/header.digest", "/renamed.csv", ',', false)
+		assert.NoError(t, err)
+		ctx.hasHeader = true
+
+		outStream := &bytes.Buffer{}
+		errStream := &bytes.Buffer{}
+		differs, err := runContext(context.Background(), ctx, outStream, errStream)
+
+		assert.NoError(t, err)
+		assert.True(t, differs)
+		assert.Equal(t, "2,ryan,23,MODIFIED\n", outStream.String())
+
+		ctx, err = NewContext(fs, digest.Positions{0}, nil, nil, nil, "rowmark", "/header.digest", "/renamed.csv", ',', false)
+		assert.NoError(t, err)
+
+		_, err = runContext(context.Background(), ctx, outStream, errStream)
+
+		assert.EqualError(t, err, "error processing base file: snapshot has header true, not false")
+	})
+}
@@ -1,15 +1,25 @@
 module github.com/aswinkarthik/csvdiff
 
 require (
-	github.com/OneOfOne/xxhash v1.2.5 // indirect
 	github.com/cespare/xxhash v1.1.0
 	github.com/fatih/color v1.7.0
-	github.com/mattn/go-colorable v0.1.2 // indirect
-	github.com/spaolacci/murmur3 v1.1.0 // indirect
+	github.com/mattn/go-isatty v0.0.8
 	github.com/spf13/afero v1.1.2
 	github.com/spf13/cobra v0.0.5
 	github.com/stretchr/testify v1.4.0
+)
+
+require (
+	github.com/OneOfOne/xxhash v1.2.5 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/mattn/go-colorable v0.1.2 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/spaolacci/murmur3 v1.1.0 // indirect
+	github.com/spf13/pflag v1.0.3 // indirect
 	golang.org/x/sys v0.0.0-20190804053845-51ab0e2deafa // indirect
+	golang.org/x/text v0.3.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
 
-go 1.13
+go 1.19
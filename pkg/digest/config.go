@@ -1,6 +1,12 @@
 package digest
 
-import "io"
+import (
+	"io"
+	"math"
+	"sort"
+
+	"github.com/cespare/xxhash"
+)
 
 // Config represents configurations that can be passed
 // to create a Digest.
@@ -8,13 +14,45 @@ import "io"
 // Key: The primary key positions
 // Value: The Value positions that needs to be compared for diff
 // Include: Include these positions in output. It is Value positions by default.
+// Duplicates: What to do when a primary key appears on more than one line. Warn by default.
+// Verify: Confirm every hash match by comparing the cells. Hashes are trusted by default.
+// MaxMemory: Bytes of memory to bound the diff to by spilling to disk, at least 64KB. The diff is in memory if zero.
+// SpillDir: Directory for the spill files. os.TempDir() by default.
+// Sorted: The file is sorted by Key. Sorted files are diffed in lockstep in constant memory.
+// Collation: How the keys of Sorted files compare. Text by default. Only for base, which sets it for both files.
+// Order: How the rows of Differences are ordered. File order by default.
+// Progress: Called as rows are read and the diff moves from phase to phase. Calls are never concurrent.
+// Index: Hold only the hashes and offset of each base row in memory and read rows again for output. Needs an io.ReadSeeker.
+// Rekeys: Pair deletions with additions whose values other than the key are the same and report them as Rekeyings.
+// Reorders: Report the rows found in both files whose place among the other rows changed as Moves.
+// Snapshot: Reader holds a snapshot written by WriteSnapshot instead of csv. Only for base, which is then diffed in memory.
+// Header: Names of the columns to name the changed columns of modifications with. Only for base.
+// SkipHeader: The first line of the file is a header and is not diffed.
+// Unchanged: Send the rows found in both files with the same values as UnchangedChange. Only for base.
+// Sample: Diff only the rows whose key hash falls in this fraction of the hash space. All rows if zero. Only for base.
 type Config struct {
-	Key         Positions
-	Value       Positions
-	Include     Positions
-	Reader      io.Reader
-	Separator   rune
-	LazyQuotes  bool
+	Key        Positions
+	Value      Positions
+	Include    Positions
+	Reader     io.Reader
+	Separator  rune
+	LazyQuotes bool
+	Duplicates DuplicatePolicy
+	Verify     bool
+	MaxMemory  int64
+	SpillDir   string
+	Sorted     bool
+	Collation  Collation
+	Order      Order
+	Progress   func(Progress)
+	Index      bool
+	Rekeys     bool
+	Reorders   bool
+	Snapshot   bool
+	Header     []string
+	SkipHeader bool
+	Unchanged  bool
+	Sample     float64
 }
 
 // NewConfig creates an instance of Config struct.
@@ -39,3 +77,94 @@ func NewConfig(
 		LazyQuotes: lazyQuotes,
 	}
 }
+
+// sampled tells if the rows with the key hash are in the sample.
+// All rows are if Sample is not a fraction below 1.
+func (c Config) sampled(key uint64) bool {
+	if c.Sample <= 0 || c.Sample >= 1 {
+		return true
+	}
+	return key < uint64(c.Sample*math.MaxUint64)
+}
+
+// sampledRow tells if the row is in the sample,
+// hashing its key like CreateDigest does
+func (c Config) sampledRow(row []string) bool {
+	if c.Sample <= 0 || c.Sample >= 1 {
+		return true
+	}
+	return c.sampled(xxhash.Sum64String(c.Key.encode(row, string(c.Separator))))
+}
+
+// sample returns the digests in the sample, reusing the slice
+func (c Config) sample(digests []Digest) []Digest {
+	if c.Sample <= 0 || c.Sample >= 1 {
+		return digests
+	}
+
+	sampled := digests[:0]
+	for _, d := range digests {
+		if c.sampled(d.Key) {
+			sampled = append(sampled, d)
+		}
+	}
+	return sampled
+}
+
+// sendsUnchanged tells if the rows found in both files
+// with the same values are sent as unchanged messages
+func (c Config) sendsUnchanged() bool {
+	return c.Reorders || c.Unchanged
+}
+
+// sameKey tells if two rows whose keys hash alike have the same key.
+// The hash is trusted unless Verify is set.
+func (c Config) sameKey(original, current []string) bool {
+	return !c.Verify || c.Key.equal(original, current)
+}
+
+// sameValue tells if two rows with the same key have the same values.
+// Matching hashes are trusted unless Verify is set.
+func (c Config) sameValue(original, current Digest) bool {
+	return original.Value == current.Value && (!c.Verify || c.Value.equal(original.Source, current.Source))
+}
+
+// byKey splits base and delta rows sharing a key hash by their key
+// cells, each in line order. The hash is trusted unless Verify is set.
+func (c Config) byKey(original, current []Digest) [][2][]Digest {
+	if !c.Verify {
+		sortByLine(original)
+		sortByLine(current)
+		return [][2][]Digest{{original, current}}
+	}
+
+	var groups [][2][]Digest
+	index := make(map[string]int)
+	for file, rows := range [2][]Digest{original, current} {
+		for _, group := range groupByKey(c.Key, rows) {
+			k := c.Key.String(group[0].Source, ',')
+			i, found := index[k]
+			if !found {
+				i = len(groups)
+				index[k] = i
+				groups = append(groups, [2][]Digest{})
+			}
+			groups[i][file] = group
+		}
+	}
+
+	return groups
+}
+
+// sortChanges orders changes by line or by key as per Order
+func (c Config) sortChanges(changes []Change) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		if c.Order == KeyOrder {
+			order := compareKeys(c.Key.pluck(changes[i].row()), c.Key.pluck(changes[j].row()))
+			if order != 0 {
+				return order < 0
+			}
+		}
+		return changes[i].position().Line < changes[j].position().Line
+	})
+}
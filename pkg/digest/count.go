@@ -0,0 +1,131 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// CountDigest counts the rows of a file by their values, for files
+// whose rows have no primary key. The values are the Config.Value
+// columns, or the whole row if none are set. The row and position of
+// the first line with each value are kept.
+type CountDigest struct {
+	Counts    map[uint64]int
+	SourceMap map[uint64][]string
+	Positions map[uint64]Position
+}
+
+// NewCountDigest to instantiate a new CountDigest
+func NewCountDigest() *CountDigest {
+	return &CountDigest{
+		Counts:    make(map[uint64]int),
+		SourceMap: make(map[uint64][]string),
+		Positions: make(map[uint64]Position),
+	}
+}
+
+// Append counts a Digest by its value
+// This operation is not thread safe
+func (c *CountDigest) Append(d Digest) {
+	c.Counts[d.Value]++
+	if position, present := c.Positions[d.Value]; present && position.Line < d.Line {
+		return
+	}
+	c.SourceMap[d.Value] = d.Source
+	c.Positions[d.Value] = positionOf(d)
+}
+
+// GenerateCountDigest generates a CountDigest of the file of the engine
+func (e Engine) GenerateCountDigest() (*CountDigest, error) {
+	return e.countDigest(context.Background())
+}
+
+func (e Engine) countDigest(ctx context.Context) (*CountDigest, error) {
+	counts := NewCountDigest()
+
+	digestChannel, errorChannel := e.StreamDigestsContext(ctx)
+	for digests := range digestChannel {
+		for _, d := range digests {
+			counts.Append(d)
+		}
+	}
+	if err := <-errorChannel; err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// RowCount is a row found more times in one file than in the other.
+// Count is how many more times. Position is the first line
+// with the values of the row in that file.
+type RowCount struct {
+	Row      []string
+	Count    int
+	Position Position
+}
+
+// CountDifferences are the rows added to delta and deleted from base
+// when both files are diffed as multisets of rows without a primary key.
+// Both are in the order of their first line.
+type CountDifferences struct {
+	Additions []RowCount
+	Deletions []RowCount
+}
+
+// Changed tells if any rows were added or deleted
+func (d CountDifferences) Changed() bool {
+	return len(d.Additions) > 0 || len(d.Deletions) > 0
+}
+
+// DiffCounts diffs baseConfig and deltaConfig as multisets of rows
+// without a primary key. Rows are the same if their values are, and
+// a row found more times in one file than in the other is added or
+// deleted that many times. Only one row per value is held in memory.
+func DiffCounts(baseConfig, deltaConfig Config) (CountDifferences, error) {
+	return DiffCountsContext(context.Background(), baseConfig, deltaConfig)
+}
+
+// DiffCountsContext is DiffCounts stopping once ctx is done
+func DiffCountsContext(ctx context.Context, baseConfig, deltaConfig Config) (CountDifferences, error) {
+	progress := newProgressTracker(baseConfig.Progress)
+
+	progress.phase(ReadingBase)
+	baseEngine := NewEngine(baseConfig)
+	baseEngine.onRead = progress.reader(Base)
+	base, err := baseEngine.countDigest(ctx)
+	if err != nil {
+		return CountDifferences{}, fmt.Errorf("error processing base file: %v", err)
+	}
+
+	progress.phase(ReadingDelta)
+	deltaEngine := NewEngine(deltaConfig)
+	deltaEngine.onRead = progress.reader(Delta)
+	delta, err := deltaEngine.countDigest(ctx)
+	if err != nil {
+		return CountDifferences{}, fmt.Errorf("error processing delta file: %v", err)
+	}
+
+	diff := CountDifferences{Additions: make([]RowCount, 0), Deletions: make([]RowCount, 0)}
+	for value, count := range delta.Counts {
+		if surplus := count - base.Counts[value]; surplus > 0 {
+			diff.Additions = append(diff.Additions, RowCount{Row: delta.SourceMap[value], Count: surplus, Position: delta.Positions[value]})
+		}
+	}
+	for value, count := range base.Counts {
+		if surplus := count - delta.Counts[value]; surplus > 0 {
+			diff.Deletions = append(diff.Deletions, RowCount{Row: base.SourceMap[value], Count: surplus, Position: base.Positions[value]})
+		}
+	}
+	sortRowCounts(diff.Additions)
+	sortRowCounts(diff.Deletions)
+
+	return diff, nil
+}
+
+func sortRowCounts(counts []RowCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Position.Line < counts[j].Position.Line
+	})
+}
@@ -0,0 +1,59 @@
+package digest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffCounts(t *testing.T) {
+	config := func(csv string, value []int) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Value: value, Separator: ','}
+	}
+
+	t.Run("should diff rows as multisets", func(t *testing.T) {
+		base := "a,1\nb,2\na,1\nc,3\nc,3\n"
+		delta := "a,1\nb,2\nb,2\nc,3\nd,4\nd,4\n"
+
+		diff, err := digest.DiffCounts(config(base, nil), config(delta, nil))
+
+		expected := digest.CountDifferences{
+			Additions: []digest.RowCount{
+				{Row: []string{"b", "2"}, Count: 1, Position: digest.Position{Line: 2, Offset: 4}},
+				{Row: []string{"d", "4"}, Count: 2, Position: digest.Position{Line: 5, Offset: 16}},
+			},
+			Deletions: []digest.RowCount{
+				{Row: []string{"a", "1"}, Count: 1, Position: digest.Position{Line: 1, Offset: 0}},
+				{Row: []string{"c", "3"}, Count: 1, Position: digest.Position{Line: 4, Offset: 12}},
+			},
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, expected, diff)
+		assert.True(t, diff.Changed())
+	})
+
+	t.Run("should tell rows apart by the value columns", func(t *testing.T) {
+		diff, err := digest.DiffCounts(config("a,1\nb,1\n", []int{1}), config("c,1\nd,1\n", []int{1}))
+
+		assert.NoError(t, err)
+		assert.Empty(t, diff.Additions)
+		assert.Empty(t, diff.Deletions)
+		assert.False(t, diff.Changed())
+	})
+
+	t.Run("should not depend on the order of the rows", func(t *testing.T) {
+		diff, err := digest.DiffCounts(config("a,1\nb,2\na,1\n", nil), config("a,1\na,1\nb,2\n", nil))
+
+		assert.NoError(t, err)
+		assert.False(t, diff.Changed())
+	})
+
+	t.Run("should name the file of an error", func(t *testing.T) {
+		_, err := digest.DiffCounts(config("a,1\n", nil), config("a,1\nb\n", nil))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "error processing delta file")
+	})
+}
@@ -1,6 +1,7 @@
 package digest
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 )
@@ -11,39 +12,231 @@ const (
 	addition     messageType = iota
 	modification messageType = iota
 	deletion     messageType = iota
+	duplicate    messageType = iota
+	rekey        messageType = iota
+	move         messageType = iota
+	// unchanged is a row found in both files with the same values.
+	// It is only sent if Config.Reorders or Config.Unchanged is set
+	// and leaves StreamDiff only for the latter.
+	unchanged messageType = iota
+)
+
+// File identifies which of the two csv files a row came from
+type File int
+
+const (
+	// Base is the file being compared against
+	Base File = iota
+	// Delta is the file containing the changes
+	Delta
+)
+
+func (f File) String() string {
+	if f == Delta {
+		return "delta"
+	}
+	return "base"
+}
+
+// Order decides how the rows of Differences are ordered
+type Order int
+
+const (
+	// FileOrder orders additions and modifications by their line
+	// in delta and deletions by their line in base.
+	FileOrder Order = iota
+	// KeyOrder orders all rows by primary key. Keys compare column
+	// by column and byte by byte, so 10 comes before 9. Rows sharing a key
+	// are ordered by line.
+	KeyOrder
 )
 
 // Differences represents the differences
 // between 2 csv content
+//
+// Duplicates is nil unless a primary key repeats in base or delta.
+// Rekeyings is nil unless Config.Rekeys is set
+// and Moves is nil unless Config.Reorders is set.
+// Unchanged counts the rows found in both files with the same values
+// if Config.Unchanged is set.
 type Differences struct {
 	Additions     []Addition
 	Modifications []Modification
 	Deletions     []Deletion
+	Duplicates    []Duplicate
+	Rekeyings     []Rekeying
+	Moves         []Move
+	Unchanged     int
+}
+
+// Changed tells if rows were added, modified, deleted, rekeyed or moved.
+// Duplicate keys are not changes.
+func (d Differences) Changed() bool {
+	return len(d.Additions) > 0 || len(d.Modifications) > 0 || len(d.Deletions) > 0 ||
+		len(d.Rekeyings) > 0 || len(d.Moves) > 0
+}
+
+// Position locates a row in its file by the line number
+// and the byte offset the row starts at
+type Position struct {
+	Line   int
+	Offset int64
+}
+
+func positionOf(d Digest) Position {
+	return Position{Line: d.Line, Offset: d.Offset}
 }
 
 // Addition is a row appearing in delta but missing in base
-type Addition []string
+type Addition struct {
+	Row      []string
+	Position Position
+}
 
 // Deletion is a row appearing in base but missing in delta
-type Deletion []string
+type Deletion struct {
+	Row      []string
+	Position Position
+}
 
 // Modification is a row present in both delta and base
-// with the values column changed in delta
+// with the values column changed in delta.
+//
+// Columns are the columns whose cells changed, compared or not.
+// It is nil if the original row is not known, as in a snapshot without rows.
 type Modification struct {
-	Original []string
-	Current  []string
+	Original         []string
+	Current          []string
+	OriginalPosition Position
+	CurrentPosition  Position
+	Columns          []ColumnChange
+}
+
+// ColumnChange is a column whose cell changed in a modified row.
+// Name is the name of the column in Config.Header, if set.
+// Compared tells if the column is one of the Config.Value columns.
+// Other columns can change as well but never make a row modified.
+type ColumnChange struct {
+	Index    int
+	Name     string
+	Compared bool
+}
+
+// Rekeying is a row deleted from base and added to delta under
+// a different primary key, with the values otherwise the same
+type Rekeying struct {
+	OriginalKey      []string
+	CurrentKey       []string
+	Original         []string
+	Current          []string
+	OriginalPosition Position
+	CurrentPosition  Position
 }
 
+// Move is a row found in both files whose place among the other
+// rows changed. OriginalIndex and CurrentIndex are the numbers of
+// the row in base and delta, counting from 1.
+type Move struct {
+	Row              []string
+	OriginalIndex    int
+	CurrentIndex     int
+	OriginalPosition Position
+	CurrentPosition  Position
+}
+
+// message is a change found while diffing.
+// current is the base row for a deletion.
+// ordinals are the numbers of the rows in base and delta for a move.
+// keyOnly is set if the base row holds only its key columns.
 type message struct {
-	original []string
-	current  []string
-	_type    messageType
+	original  Digest
+	current   Digest
+	duplicate Duplicate
+	ordinals  [2]int
+	keyOnly   bool
+	_type     messageType
 }
 
-// Diff finds the Differences between baseConfig and deltaConfig
+// Diff finds the Differences between baseConfig and deltaConfig.
+// It collects the changes of StreamDiff and orders them as per
+// the Order of baseConfig.
 func Diff(baseConfig, deltaConfig Config) (Differences, error) {
-	baseEngine := NewEngine(baseConfig)
-	baseDigestChannel, baseErrorChannel := baseEngine.StreamDigests()
+	return DiffContext(context.Background(), baseConfig, deltaConfig)
+}
+
+// DiffContext is Diff stopping once ctx is done.
+// It returns the error of ctx if the diff did not complete.
+func DiffContext(ctx context.Context, baseConfig, deltaConfig Config) (Differences, error) {
+	changeChannel, errorChannel := StreamDiffContext(ctx, baseConfig, deltaConfig)
+
+	c := &collector{}
+	c.collect(changeChannel)
+	if err := <-errorChannel; err != nil {
+		return Differences{}, err
+	}
+
+	return c.differences(baseConfig), nil
+}
+
+// Differs tells if baseConfig and deltaConfig differ as per Differences.Changed.
+// It stops diffing at the first change and holds no rows.
+func Differs(baseConfig, deltaConfig Config) (bool, error) {
+	return DiffersContext(context.Background(), baseConfig, deltaConfig)
+}
+
+// DiffersContext is Differs stopping once ctx is done.
+// It returns the error of ctx if the diff stopped before a change was found.
+func DiffersContext(ctx context.Context, baseConfig, deltaConfig Config) (bool, error) {
+	stop, cancel := context.WithCancel(ctx)
+	defer cancel()
+	changeChannel, errorChannel := StreamDiffContext(stop, baseConfig, deltaConfig)
+
+	differs := false
+	for change := range changeChannel {
+		if change.Type != DuplicateChange && change.Type != UnchangedChange {
+			differs = true
+			cancel()
+		}
+	}
+	err := <-errorChannel
+	if differs {
+		// the error is the one of stopping early
+		return true, nil
+	}
+
+	return false, err
+}
+
+// deltaPrefetch is how many batches of delta digests are buffered
+// while base is read.
+const deltaPrefetch = 4
+
+// diffInMemory holds the digests of base in memory
+// and streams the digests of delta against them.
+// Both files are digested at the same time. Delta digests wait until
+// the base map is ready, and reading delta pauses once deltaPrefetch
+// batches are buffered and one batch per CPU is digested. So at most
+// deltaPrefetch+NumCPU batches of bufferSize rows of delta are held
+// besides the base map.
+func diffInMemory(ctx context.Context, baseConfig, deltaConfig Config, progress *progressTracker, emit func(message)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	progress.phase(ReadingBase)
+	var baseDigestChannel chan []Digest
+	var baseErrorChannel chan error
+	var keyOnly bool
+	if baseConfig.Snapshot {
+		baseDigestChannel, baseErrorChannel = streamSnapshot(ctx, baseConfig, deltaConfig, progress.reader(Base), &keyOnly)
+	} else {
+		baseEngine := NewEngine(baseConfig)
+		baseEngine.onRead = progress.reader(Base)
+		baseDigestChannel, baseErrorChannel = baseEngine.StreamDigestsContext(ctx)
+	}
+	deltaEngine := NewEngine(deltaConfig)
+	deltaEngine.onRead = progress.reader(Delta)
+	deltaEngine.batches = deltaPrefetch
+	deltaDigestChannel, deltaErrorChannel := deltaEngine.StreamDigestsContext(ctx)
 
 	baseFileDigest := NewFileDigest()
 	for digests := range baseDigestChannel {
@@ -53,65 +246,316 @@ func Diff(baseConfig, deltaConfig Config) (Differences, error) {
 	}
 
 	if err := <-baseErrorChannel; err != nil {
-		return Differences{}, fmt.Errorf("error processing base file: %v", err)
+		return fmt.Errorf("error processing base file: %v", err)
+	}
+	if keyOnly {
+		emitRow := emit
+		emit = func(msg message) {
+			msg.keyOnly = true
+			emitRow(msg)
+		}
 	}
 
-	deltaEngine := NewEngine(deltaConfig)
-	deltaDigestChannel, deltaErrorChannel := deltaEngine.StreamDigests()
+	if err := emitBase(baseFileDigest, baseConfig, emit); err != nil {
+		return err
+	}
+
+	progress.phase(ReadingDelta)
 
-	additions := make([]Addition, 0)
-	modifications := make([]Modification, 0)
-	deletions := make([]Deletion, 0)
+	duplicates := emitDifferences(ctx, baseFileDigest, deltaDigestChannel, baseConfig, emit)
 
-	msgChannel := streamDifferences(baseFileDigest, deltaDigestChannel)
+	if err := <-deltaErrorChannel; err != nil {
+		return fmt.Errorf("error processing delta file: %v", err)
+	}
+
+	return checkDelta(duplicates, deltaConfig)
+}
+
+// emitBase emits the duplicates of the base file
+// or returns an error if the base config does not allow them.
+func emitBase(base *FileDigest, config Config, emit func(message)) error {
+	if config.Verify {
+		if err := collisionIn(Base, config.Key, base.Duplicates); err != nil {
+			return err
+		}
+	}
+
+	duplicates := duplicatesIn(Base, config.Key, base.Duplicates)
+	if len(duplicates) > 0 && config.Duplicates == DuplicateFail {
+		return &DuplicateKeyError{duplicates[0]}
+	}
+
+	for _, d := range duplicates {
+		emit(message{_type: duplicate, duplicate: d})
+	}
+
+	return nil
+}
+
+// checkDelta returns an error if the delta file has
+// duplicates and the delta config does not allow them.
+func checkDelta(duplicates []Duplicate, config Config) error {
+	if config.Duplicates != DuplicateFail || len(duplicates) == 0 {
+		return nil
+	}
+
+	return &DuplicateKeyError{duplicates[0]}
+}
+
+// emitDifferences diffs the digests of delta against base.
+// It returns the duplicates found in delta.
+func emitDifferences(ctx context.Context, base *FileDigest, digestChannel chan []Digest, config Config, emit func(message)) []Duplicate {
+	var msgChannel chan message
+	if config.Duplicates == DuplicateMultiset {
+		msgChannel = streamMultisetDifferences(ctx, base, digestChannel, config)
+	} else {
+		msgChannel = streamDifferences(ctx, base, digestChannel, config)
+	}
+
+	var duplicates []Duplicate
 	for msg := range msgChannel {
-		switch msg._type {
-		case addition:
-			additions = append(additions, msg.current)
-		case modification:
-			modifications = append(modifications, Modification{Original: msg.original, Current: msg.current})
-		case deletion:
-			deletions = append(deletions, msg.current)
+		if msg._type == duplicate {
+			duplicates = append(duplicates, msg.duplicate)
+		}
+		emit(msg)
+	}
+
+	return duplicates
+}
+
+// collector gathers changes into Differences
+type collector struct {
+	additions     []Change
+	modifications []Change
+	deletions     []Change
+	rekeyings     []Change
+	moves         []Change
+	duplicates    []Duplicate
+	unchanged     int
+}
+
+func (c *collector) collect(changeChannel chan Change) {
+	for change := range changeChannel {
+		switch change.Type {
+		case AdditionChange:
+			c.additions = append(c.additions, change)
+		case ModificationChange:
+			c.modifications = append(c.modifications, change)
+		case DeletionChange:
+			c.deletions = append(c.deletions, change)
+		case DuplicateChange:
+			c.duplicates = append(c.duplicates, change.Duplicate)
+		case RekeyChange:
+			c.rekeyings = append(c.rekeyings, change)
+		case MoveChange:
+			c.moves = append(c.moves, change)
+		case UnchangedChange:
+			c.unchanged++
 		default:
 			continue
 		}
 	}
+}
 
-	if err := <-deltaErrorChannel; err != nil {
-		return Differences{}, fmt.Errorf("error processing delta file: %v", err)
+// differences orders the changes collected as the config asks
+func (c *collector) differences(config Config) Differences {
+	for _, changes := range [][]Change{c.additions, c.modifications, c.deletions, c.rekeyings, c.moves} {
+		config.sortChanges(changes)
+	}
+	sortDuplicates(c.duplicates)
+
+	additions := make([]Addition, 0, len(c.additions))
+	for _, change := range c.additions {
+		additions = append(additions, change.Addition)
+	}
+	modifications := make([]Modification, 0, len(c.modifications))
+	for _, change := range c.modifications {
+		modifications = append(modifications, change.Modification)
+	}
+	deletions := make([]Deletion, 0, len(c.deletions))
+	for _, change := range c.deletions {
+		deletions = append(deletions, change.Deletion)
+	}
+
+	var rekeyings []Rekeying
+	if config.Rekeys {
+		rekeyings = make([]Rekeying, 0, len(c.rekeyings))
+	}
+	for _, change := range c.rekeyings {
+		rekeyings = append(rekeyings, change.Rekeying)
+	}
+
+	var moves []Move
+	if config.Reorders {
+		moves = make([]Move, 0, len(c.moves))
+	}
+	for _, change := range c.moves {
+		moves = append(moves, change.Move)
 	}
 
-	return Differences{Additions: additions, Modifications: modifications, Deletions: deletions}, nil
+	return Differences{
+		Additions:     additions,
+		Modifications: modifications,
+		Deletions:     deletions,
+		Duplicates:    c.duplicates,
+		Rekeyings:     rekeyings,
+		Moves:         moves,
+		Unchanged:     c.unchanged,
+	}
 }
 
-func streamDifferences(baseFileDigest *FileDigest, digestChannel chan []Digest) chan message {
+// streamDifferences diffs the digests of delta against base as they stream in.
+// It stops once ctx is done, even if msgChannel is no longer read.
+func streamDifferences(ctx context.Context, baseFileDigest *FileDigest, digestChannel chan []Digest, config Config) chan message {
 	maxProcs := runtime.NumCPU()
 	msgChannel := make(chan message, maxProcs*bufferSize)
 
 	go func(base *FileDigest, digestChannel chan []Digest, msgChannel chan message) {
 		defer close(msgChannel)
+		send := sender(ctx, msgChannel)
 
+		deltaKeys := newDuplicateTracker(base, config.Key)
 		for digests := range digestChannel {
 			for _, d := range digests {
-				if baseValue, present := base.Digests[d.Key]; present {
-					if baseValue != d.Value {
-						// Modification
-						msgChannel <- message{_type: modification, current: d.Source, original: base.SourceMap[d.Key]}
+				_, present := base.Digests[d.Key]
+				if present && !config.sameKey(base.SourceMap[d.Key], d.Source) {
+					// A different key with the same hash
+					if !send(message{_type: addition, current: d}) {
+						return
 					}
-					// delete from sourceMap so that at the end only deletions are left in base
-					delete(base.SourceMap, d.Key)
-				} else {
+					continue
+				}
+
+				deltaKeys.add(d)
+				if !present {
 					// Addition
-					msgChannel <- message{_type: addition, current: d.Source}
+					if !send(message{_type: addition, current: d}) {
+						return
+					}
+					continue
+				}
+
+				// every row of a repeated base key is diffed on its own
+				for _, original := range base.rows(d.Key) {
+					if !config.sameKey(original.Source, d.Source) {
+						continue
+					}
+					if !config.sameValue(original, d) {
+						// Modification
+						if !send(message{_type: modification, current: d, original: original}) {
+							return
+						}
+					} else if config.sendsUnchanged() {
+						if !send(message{_type: unchanged, current: d, original: original}) {
+							return
+						}
+					}
 				}
 			}
 		}
 
-		for _, value := range base.SourceMap {
-			msgChannel <- message{_type: deletion, current: value}
+		// only the keys never seen in delta are deletions
+		for k := range base.SourceMap {
+			if deltaKeys.seen(k) {
+				continue
+			}
+			for _, d := range base.rows(k) {
+				if !send(message{_type: deletion, current: d}) {
+					return
+				}
+			}
+		}
+
+		for _, d := range deltaKeys.found() {
+			if !send(message{_type: duplicate, duplicate: d}) {
+				return
+			}
 		}
 
 	}(baseFileDigest, digestChannel, msgChannel)
 
 	return msgChannel
 }
+
+// streamMultisetDifferences cancels out identical rows as they stream in.
+// Rows left without an identical partner are paired up by key at the end.
+func streamMultisetDifferences(ctx context.Context, baseFileDigest *FileDigest, digestChannel chan []Digest, config Config) chan message {
+	maxProcs := runtime.NumCPU()
+	msgChannel := make(chan message, maxProcs*bufferSize)
+
+	go func(base *FileDigest, digestChannel chan []Digest, msgChannel chan message) {
+		defer close(msgChannel)
+		send := sender(ctx, msgChannel)
+
+		deltaKeys := newDuplicateTracker(base, config.Key)
+		pending := make(map[uint64][]Digest)
+		for digests := range digestChannel {
+			for _, d := range digests {
+				firstLine := deltaKeys.add(d)
+				original, consumed := base.consume(d, config)
+				if !consumed {
+					pending[d.Key] = append(pending[d.Key], d)
+					continue
+				}
+				deltaKeys.consumed(d, firstLine)
+				if config.sendsUnchanged() {
+					if !send(message{_type: unchanged, original: original, current: d}) {
+						return
+					}
+				}
+			}
+		}
+
+		for k, pendingRows := range pending {
+			for _, rows := range config.byKey(base.take(k), pendingRows) {
+				original, current := rows[Base], rows[Delta]
+				for i := range current {
+					if i < len(original) {
+						if !send(message{_type: modification, current: current[i], original: original[i]}) {
+							return
+						}
+					} else {
+						if !send(message{_type: addition, current: current[i]}) {
+							return
+						}
+					}
+				}
+				for i := len(current); i < len(original); i++ {
+					if !send(message{_type: deletion, current: original[i]}) {
+						return
+					}
+				}
+			}
+		}
+
+		for k := range base.positions {
+			for _, d := range base.take(k) {
+				if !send(message{_type: deletion, current: d}) {
+					return
+				}
+			}
+		}
+
+		for _, d := range deltaKeys.found() {
+			if !send(message{_type: duplicate, duplicate: d}) {
+				return
+			}
+		}
+
+	}(baseFileDigest, digestChannel, msgChannel)
+
+	return msgChannel
+}
+
+// sender returns a function sending on msgChannel unless ctx is done first.
+// The function returns false if ctx is done.
+func sender(ctx context.Context, msgChannel chan message) func(message) bool {
+	return func(msg message) bool {
+		select {
+		case msgChannel <- msg:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
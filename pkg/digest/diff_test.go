@@ -44,21 +44,27 @@ func TestDiff(t *testing.T) {
 
 				expected := digest.Differences{
 					Additions: []digest.Addition{
-						strings.Split("4,col-1,col-2,col-3,four-value-added", ","),
-						strings.Split("5,col-1,col-2,col-3,five-value-added", ","),
+						{Row: strings.Split("4,col-1,col-2,col-3,four-value-added", ","), Position: digest.Position{Line: 3, Offset: 69}},
+						{Row: strings.Split("5,col-1,col-2,col-3,five-value-added", ","), Position: digest.Position{Line: 5, Offset: 160}},
 					},
 					Modifications: []digest.Modification{
 						{
-							Current:  strings.Split("2,col-1,col-2,col-3,two-value-modified", ","),
-							Original: strings.Split("2,col-1,col-2,col-3,two-value", ","),
+							Current:          strings.Split("2,col-1,col-2,col-3,two-value-modified", ","),
+							Original:         strings.Split("2,col-1,col-2,col-3,two-value", ","),
+							CurrentPosition:  digest.Position{Line: 2, Offset: 30},
+							OriginalPosition: digest.Position{Line: 2, Offset: 30},
+							Columns:          []digest.ColumnChange{{Index: 4, Compared: true}},
 						},
 						{
-							Current:  strings.Split("100,col-1-modified,col-2,col-3,hundred-value-modified", ","),
-							Original: strings.Split("100,col-1,col-2,col-3,hundred-value", ","),
+							Current:          strings.Split("100,col-1-modified,col-2,col-3,hundred-value-modified", ","),
+							Original:         strings.Split("100,col-1,col-2,col-3,hundred-value", ","),
+							CurrentPosition:  digest.Position{Line: 4, Offset: 106},
+							OriginalPosition: digest.Position{Line: 4, Offset: 92},
+							Columns:          []digest.ColumnChange{{Index: 1, Compared: true}, {Index: 4, Compared: true}},
 						},
 					},
 					Deletions: []digest.Deletion{
-						strings.Split("3,col-1,col-2,col-3,three-value", ","),
+						{Row: strings.Split("3,col-1,col-2,col-3,three-value", ","), Position: digest.Position{Line: 3, Offset: 60}},
 					},
 				}
 
@@ -93,21 +99,27 @@ func TestDiff(t *testing.T) {
 
 		expected := digest.Differences{
 			Additions: []digest.Addition{
-				strings.Split("4,col-1,col-2,col-3,four\"-added", ","),
-				strings.Split("5,col-1,col-2,col-3,five\"-added", ","),
+				{Row: strings.Split("4,col-1,col-2,col-3,four\"-added", ","), Position: digest.Position{Line: 3, Offset: 69}},
+				{Row: strings.Split("5,col-1,col-2,col-3,five\"-added", ","), Position: digest.Position{Line: 5, Offset: 155}},
 			},
 			Modifications: []digest.Modification{
 				{
-					Current:  strings.Split("2,col-1,col-2,col-3,two-value-modified", ","),
-					Original: strings.Split("2,col-1,col-2,col-3,two-value", ","),
+					Current:          strings.Split("2,col-1,col-2,col-3,two-value-modified", ","),
+					Original:         strings.Split("2,col-1,col-2,col-3,two-value", ","),
+					CurrentPosition:  digest.Position{Line: 2, Offset: 30},
+					OriginalPosition: digest.Position{Line: 2, Offset: 30},
+					Columns:          []digest.ColumnChange{{Index: 4, Compared: true}},
 				},
 				{
-					Current:  strings.Split("100,col-1-modified,col-2,col-3,hundred-value-modified", ","),
-					Original: strings.Split("100,col-1,col-2,col-3,hundred-value", ","),
+					Current:          strings.Split("100,col-1-modified,col-2,col-3,hundred-value-modified", ","),
+					Original:         strings.Split("100,col-1,col-2,col-3,hundred-value", ","),
+					CurrentPosition:  digest.Position{Line: 4, Offset: 101},
+					OriginalPosition: digest.Position{Line: 4, Offset: 92},
+					Columns:          []digest.ColumnChange{{Index: 1, Compared: true}, {Index: 4, Compared: true}},
 				},
 			},
 			Deletions: []digest.Deletion{
-				strings.Split("3,col-1,col-2,col-3,three-value", ","),
+				{Row: strings.Split("3,col-1,col-2,col-3,three-value", ","), Position: digest.Position{Line: 3, Offset: 60}},
 			},
 		}
 
@@ -116,3 +128,551 @@ func TestDiff(t *testing.T) {
 		assert.Equal(t, expected, actual)
 	})
 }
+
+func TestDiffDuplicates(t *testing.T) {
+	base := `1,one
+2,two
+2,two-again
+3,three
+`
+	delta := `1,one
+2,two-again
+3,three-modified
+3,three-modified-again
+4,four
+`
+	config := func(csv string, policy digest.DuplicatePolicy) digest.Config {
+		return digest.Config{
+			Reader:     strings.NewReader(csv),
+			Key:        []int{0},
+			Separator:  ',',
+			Duplicates: policy,
+		}
+	}
+	expectedDuplicates := []digest.Duplicate{
+		{File: digest.Base, Key: []string{"2"}, Lines: []int{2, 3}},
+		{File: digest.Delta, Key: []string{"3"}, Lines: []int{3, 4}},
+	}
+
+	t.Run("should report duplicates and diff every row of a duplicate key on its own", func(t *testing.T) {
+		actual, err := digest.Diff(config(base, digest.DuplicateWarn), config(delta, digest.DuplicateWarn))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []digest.Addition{{Row: []string{"4", "four"}, Position: digest.Position{Line: 5, Offset: 58}}}, actual.Additions)
+		assert.Equal(t, []digest.Modification{
+			{
+				Original:         []string{"2", "two"},
+				Current:          []string{"2", "two-again"},
+				OriginalPosition: digest.Position{Line: 2, Offset: 6},
+				CurrentPosition:  digest.Position{Line: 2, Offset: 6},
+				Columns:          []digest.ColumnChange{{Index: 1, Compared: true}},
+			},
+			{
+				Original:         []string{"3", "three"},
+				Current:          []string{"3", "three-modified"},
+				OriginalPosition: digest.Position{Line: 4, Offset: 24},
+				CurrentPosition:  digest.Position{Line: 3, Offset: 18},
+				Columns:          []digest.ColumnChange{{Index: 1, Compared: true}},
+			},
+			{
+				Original:         []string{"3", "three"},
+				Current:          []string{"3", "three-modified-again"},
+				OriginalPosition: digest.Position{Line: 4, Offset: 24},
+				CurrentPosition:  digest.Position{Line: 4, Offset: 35},
+				Columns:          []digest.ColumnChange{{Index: 1, Compared: true}},
+			},
+		}, actual.Modifications)
+		assert.Empty(t, actual.Deletions)
+		assert.Equal(t, expectedDuplicates, actual.Duplicates)
+	})
+
+	t.Run("should report every row of a duplicate delta key", func(t *testing.T) {
+		actual, err := digest.Diff(config("1,one\n2,two\n", digest.DuplicateWarn), config("1,one\n2,two\n2,zwei\n2,deux\n", digest.DuplicateWarn))
+
+		assert.NoError(t, err)
+		assert.Empty(t, actual.Additions)
+		assert.Equal(t, []digest.Modification{
+			{
+				Original:         []string{"2", "two"},
+				Current:          []string{"2", "zwei"},
+				OriginalPosition: digest.Position{Line: 2, Offset: 6},
+				CurrentPosition:  digest.Position{Line: 3, Offset: 12},
+				Columns:          []digest.ColumnChange{{Index: 1, Compared: true}},
+			},
+			{
+				Original:         []string{"2", "two"},
+				Current:          []string{"2", "deux"},
+				OriginalPosition: digest.Position{Line: 2, Offset: 6},
+				CurrentPosition:  digest.Position{Line: 4, Offset: 19},
+				Columns:          []digest.ColumnChange{{Index: 1, Compared: true}},
+			},
+		}, actual.Modifications)
+		assert.Empty(t, actual.Deletions)
+		assert.Equal(t, []digest.Duplicate{{File: digest.Delta, Key: []string{"2"}, Lines: []int{2, 3, 4}}}, actual.Duplicates)
+	})
+
+	t.Run("should delete every row of a duplicate base key missing from delta", func(t *testing.T) {
+		modes := map[string]func(digest.Config) digest.Config{
+			"in memory":     func(c digest.Config) digest.Config { return c },
+			"with an index": func(c digest.Config) digest.Config { c.Index = true; return c },
+			"on disk":       func(c digest.Config) digest.Config { c.MaxMemory, c.SpillDir = 1, t.TempDir(); return c },
+			"sorted":        func(c digest.Config) digest.Config { c.Sorted = true; return c },
+		}
+		for name, mode := range modes {
+			actual, err := digest.Diff(mode(config("1,one\n2,two\n2,zwei\n", digest.DuplicateWarn)), mode(config("1,one\n", digest.DuplicateWarn)))
+
+			assert.NoError(t, err, name)
+			assert.Equal(t, []digest.Deletion{
+				{Row: []string{"2", "two"}, Position: digest.Position{Line: 2, Offset: 6}},
+				{Row: []string{"2", "zwei"}, Position: digest.Position{Line: 3, Offset: 12}},
+			}, actual.Deletions, name)
+		}
+	})
+
+	t.Run("should find a delta key repeated after its base row cancelled out as a multiset", func(t *testing.T) {
+		actual, err := digest.Diff(config("1,one\n", digest.DuplicateMultiset), config("1,one\n1,one\n", digest.DuplicateMultiset))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []digest.Addition{{Row: []string{"1", "one"}, Position: digest.Position{Line: 2, Offset: 6}}}, actual.Additions)
+		assert.Equal(t, []digest.Duplicate{{File: digest.Delta, Key: []string{"1"}, Lines: []int{1, 2}}}, actual.Duplicates)
+	})
+
+	t.Run("should not report duplicates when there are none", func(t *testing.T) {
+		actual, err := digest.Diff(config(delta[:6], digest.DuplicateWarn), config(delta[:6], digest.DuplicateWarn))
+
+		assert.NoError(t, err)
+		assert.Nil(t, actual.Duplicates)
+	})
+
+	t.Run("should fail on duplicates in base", func(t *testing.T) {
+		_, err := digest.Diff(config(base, digest.DuplicateFail), config(delta, digest.DuplicateWarn))
+
+		assert.EqualError(t, err, `duplicate primary key "2" on lines 2, 3 of base file`)
+		assert.Equal(t, &digest.DuplicateKeyError{Duplicate: expectedDuplicates[0]}, err)
+	})
+
+	t.Run("should fail on duplicates in delta", func(t *testing.T) {
+		_, err := digest.Diff(config(base, digest.DuplicateWarn), config(delta, digest.DuplicateFail))
+
+		assert.EqualError(t, err, `duplicate primary key "3" on lines 3, 4 of delta file`)
+	})
+
+	t.Run("should diff duplicates as a multiset", func(t *testing.T) {
+		actual, err := digest.Diff(config(base, digest.DuplicateMultiset), config(delta, digest.DuplicateMultiset))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []digest.Addition{
+			{Row: []string{"3", "three-modified-again"}, Position: digest.Position{Line: 4, Offset: 35}},
+			{Row: []string{"4", "four"}, Position: digest.Position{Line: 5, Offset: 58}},
+		}, actual.Additions)
+		assert.Equal(t, []digest.Modification{
+			{
+				Original:         []string{"3", "three"},
+				Current:          []string{"3", "three-modified"},
+				OriginalPosition: digest.Position{Line: 4, Offset: 24},
+				CurrentPosition:  digest.Position{Line: 3, Offset: 18},
+				Columns:          []digest.ColumnChange{{Index: 1, Compared: true}},
+			},
+		}, actual.Modifications)
+		assert.Equal(t, []digest.Deletion{{Row: []string{"2", "two"}, Position: digest.Position{Line: 2, Offset: 6}}}, actual.Deletions)
+		assert.Equal(t, expectedDuplicates, actual.Duplicates)
+	})
+}
+
+func TestDiffOnDisk(t *testing.T) {
+	var base, delta strings.Builder
+	for i := 0; i < 2000; i++ {
+		base.WriteString(fmt.Sprintf("%d,value-%d\n", i, i))
+		switch {
+		case i%10 == 0:
+			delta.WriteString(fmt.Sprintf("%d,modified-%d\n", i, i))
+		case i%15 == 0:
+			// deleted
+		default:
+			delta.WriteString(fmt.Sprintf("%d,value-%d\n", i, i))
+		}
+	}
+	delta.WriteString("3000,added\n3000,added-again\n")
+
+	config := func(csv string, maxMemory int64) digest.Config {
+		return digest.Config{
+			Reader:    strings.NewReader(csv),
+			Key:       []int{0},
+			Separator: ',',
+			MaxMemory: maxMemory,
+			SpillDir:  t.TempDir(),
+		}
+	}
+
+	expected, err := digest.Diff(config(base.String(), 0), config(delta.String(), 0))
+	assert.NoError(t, err)
+
+	for _, maxMemory := range []int64{1, 64 * 1024, 1 << 30} {
+		t.Run(fmt.Sprintf("should match the in memory diff with %d bytes", maxMemory), func(t *testing.T) {
+			actual, err := digest.Diff(config(base.String(), maxMemory), config(delta.String(), maxMemory))
+
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+			assert.Len(t, actual.Duplicates, 1)
+		})
+	}
+
+	t.Run("should match the in memory diff of files larger than all partitions at the bound", func(t *testing.T) {
+		var largeBase, largeDelta strings.Builder
+		for i := 0; i < 40000; i++ {
+			largeBase.WriteString(fmt.Sprintf("%d,value-%d\n", i, i))
+			largeDelta.WriteString(fmt.Sprintf("%d,value-%d\n", i, i%7))
+		}
+		expected, err := digest.Diff(config(largeBase.String(), 0), config(largeDelta.String(), 0))
+		assert.NoError(t, err)
+
+		actual, err := digest.Diff(config(largeBase.String(), 64*1024), config(largeDelta.String(), 64*1024))
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("should fail on a parse error", func(t *testing.T) {
+		_, err := digest.Diff(config(base.String(), 1), config("1,2\n3", 1))
+
+		assert.Error(t, err)
+	})
+}
+
+func TestDiffOrder(t *testing.T) {
+	// enough rows for the digests to be spread over several batches
+	var base, delta strings.Builder
+	var expected digest.Differences
+	write := func(file *strings.Builder, row ...string) digest.Position {
+		position := digest.Position{Line: strings.Count(file.String(), "\n") + 1, Offset: int64(file.Len())}
+		file.WriteString(strings.Join(row, ",") + "\n")
+		return position
+	}
+	for i := 2000; i > 0; i-- {
+		key, value := fmt.Sprint(i), fmt.Sprintf("value-%d", i)
+		basePosition := write(&base, key, value)
+		switch {
+		case i%10 == 0:
+			modified := fmt.Sprintf("modified-%d", i)
+			expected.Modifications = append(expected.Modifications, digest.Modification{
+				Original:         []string{key, value},
+				Current:          []string{key, modified},
+				OriginalPosition: basePosition,
+				CurrentPosition:  write(&delta, key, modified),
+				Columns:          []digest.ColumnChange{{Index: 1, Compared: true}},
+			})
+		case i%15 == 0:
+			expected.Deletions = append(expected.Deletions, digest.Deletion{Row: []string{key, value}, Position: basePosition})
+		default:
+			write(&delta, key, value)
+		}
+	}
+	for i := 3000; i > 2000; i -= 100 {
+		row := []string{fmt.Sprint(i), "added"}
+		expected.Additions = append(expected.Additions, digest.Addition{Row: row, Position: write(&delta, row...)})
+	}
+
+	config := func(csv string, order digest.Order) digest.Config {
+		return digest.Config{
+			Reader:    strings.NewReader(csv),
+			Key:       []int{0},
+			Separator: ',',
+			Order:     order,
+		}
+	}
+
+	t.Run("should order rows by line", func(t *testing.T) {
+		for run := 0; run < 5; run++ {
+			actual, err := digest.Diff(config(base.String(), digest.FileOrder), config(delta.String(), digest.FileOrder))
+
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		}
+	})
+
+	t.Run("should order rows by key", func(t *testing.T) {
+		actual, err := digest.Diff(config(base.String(), digest.KeyOrder), config(delta.String(), digest.KeyOrder))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2100", "added"}, actual.Additions[0].Row)
+		assert.Equal(t, "10", actual.Modifications[0].Current[0])
+		assert.Equal(t, "100", actual.Modifications[1].Current[0])
+		assert.Equal(t, []string{"1005", "value-1005"}, actual.Deletions[0].Row)
+		assert.Len(t, actual.Deletions, len(expected.Deletions))
+	})
+
+	t.Run("should order mixed text and number keys the same on every run", func(t *testing.T) {
+		base := "9,a\n10,b\n9a,c\n"
+		var first []digest.Deletion
+		for run := 0; run < 5; run++ {
+			actual, err := digest.Diff(config(base, digest.KeyOrder), config("", digest.KeyOrder))
+
+			assert.NoError(t, err)
+			if run == 0 {
+				first = actual.Deletions
+			}
+			assert.Equal(t, first, actual.Deletions)
+		}
+		keys := make([]string, 0, len(first))
+		for _, deletion := range first {
+			keys = append(keys, deletion.Row[0])
+		}
+		assert.Equal(t, []string{"10", "9", "9a"}, keys)
+	})
+}
+
+func TestDiffSorted(t *testing.T) {
+	base := `id,name
+1,one
+2,two
+9,nine
+10,ten
+12,twelve
+`
+	delta := `id,name
+2,two-modified
+9,nine
+10,ten
+11,eleven
+12,twelve
+`
+	config := func(csv string) digest.Config {
+		return digest.Config{
+			Reader:    strings.NewReader(csv),
+			Key:       []int{0},
+			Separator: ',',
+			Sorted:    true,
+		}
+	}
+	numeric := func(csv string) digest.Config {
+		c := config(csv)
+		c.Collation = digest.NumericCollation
+		c.Header, c.SkipHeader = []string{"id", "name"}, true
+		return c
+	}
+
+	t.Run("should diff numerically sorted files in lockstep", func(t *testing.T) {
+		expected := digest.Differences{
+			Additions: []digest.Addition{{Row: []string{"11", "eleven"}, Position: digest.Position{Line: 5, Offset: 37}}},
+			Modifications: []digest.Modification{{
+				Original:         []string{"2", "two"},
+				Current:          []string{"2", "two-modified"},
+				OriginalPosition: digest.Position{Line: 3, Offset: 14},
+				CurrentPosition:  digest.Position{Line: 2, Offset: 8},
+				Columns:          []digest.ColumnChange{{Index: 1, Name: "name", Compared: true}},
+			}},
+			Deletions: []digest.Deletion{{Row: []string{"1", "one"}, Position: digest.Position{Line: 2, Offset: 8}}},
+		}
+
+		actual, err := digest.Diff(numeric(base), numeric(delta))
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("should diff files sorted as text in lockstep", func(t *testing.T) {
+		actual, err := digest.Diff(config("10,ten\n2,two\n9a,nine\n"), config("10,ten\n9,nine\n9a,nine\n"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []digest.Addition{{Row: []string{"9", "nine"}, Position: digest.Position{Line: 2, Offset: 7}}}, actual.Additions)
+		assert.Equal(t, []digest.Deletion{{Row: []string{"2", "two"}, Position: digest.Position{Line: 2, Offset: 7}}}, actual.Deletions)
+	})
+
+	t.Run("should fail if a file is not sorted", func(t *testing.T) {
+		_, err := digest.Diff(numeric(base), numeric("id,name\n1,one\n3,three\n2,two\n"))
+
+		assert.EqualError(t, err, `delta file is not sorted by primary key: "2" on line 4 comes after "3"`)
+		assert.IsType(t, &digest.UnsortedError{}, err)
+	})
+
+	t.Run("should fail if a numerically sorted file is sorted as text", func(t *testing.T) {
+		_, err := digest.Diff(config("1,one\n9,nine\n10,ten\n"), config("1,one\n"))
+
+		assert.EqualError(t, err, `base file is not sorted by primary key: "10" on line 3 comes after "9"`)
+	})
+
+	t.Run("should check the first row without a header", func(t *testing.T) {
+		_, err := digest.Diff(config("2,two\n1,one\n"), config("2,two\n1,one\n"))
+
+		assert.EqualError(t, err, `base file is not sorted by primary key: "1" on line 2 comes after "2"`)
+	})
+
+	t.Run("should fail on a key that is not a number when sorted numerically", func(t *testing.T) {
+		_, err := digest.Diff(numeric(base), numeric("id,name\n1,one\n9a,nine\n"))
+
+		assert.EqualError(t, err, `delta file has key "9a" on line 3 that is not a number. Numeric sorting needs numbers`)
+	})
+
+	t.Run("should diff runs of duplicate keys", func(t *testing.T) {
+		base := "1,a\n1,b\n2,c\n"
+		delta := "1,b\n1,d\n1,e\n2,c\n"
+		baseConfig, deltaConfig := config(base), config(delta)
+		baseConfig.Duplicates = digest.DuplicateMultiset
+
+		actual, err := digest.Diff(baseConfig, deltaConfig)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []digest.Modification{{
+			Original:         []string{"1", "a"},
+			Current:          []string{"1", "d"},
+			OriginalPosition: digest.Position{Line: 1, Offset: 0},
+			CurrentPosition:  digest.Position{Line: 2, Offset: 4},
+			Columns:          []digest.ColumnChange{{Index: 1, Compared: true}},
+		}}, actual.Modifications)
+		assert.Equal(t, []digest.Addition{{Row: []string{"1", "e"}, Position: digest.Position{Line: 3, Offset: 8}}}, actual.Additions)
+		assert.Empty(t, actual.Deletions)
+		assert.Equal(t, []digest.Duplicate{
+			{File: digest.Base, Key: []string{"1"}, Lines: []int{1, 2}},
+			{File: digest.Delta, Key: []string{"1"}, Lines: []int{1, 2, 3}},
+		}, actual.Duplicates)
+	})
+}
+
+func TestDiffHeader(t *testing.T) {
+	base := "id,name\n1,one\n2,two\n"
+	delta := "key,label\n1,one\n2,zwei\n"
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ',', SkipHeader: true}
+	}
+
+	modes := []struct {
+		name   string
+		config func(digest.Config) digest.Config
+	}{
+		{"in memory", func(c digest.Config) digest.Config { return c }},
+		{"with an index", func(c digest.Config) digest.Config { c.Index = true; return c }},
+		{"as a multiset", func(c digest.Config) digest.Config { c.Duplicates = digest.DuplicateMultiset; return c }},
+		{"on disk", func(c digest.Config) digest.Config { c.MaxMemory = 1; return c }},
+		{"sorted", func(c digest.Config) digest.Config { c.Sorted = true; return c }},
+	}
+	for _, mode := range modes {
+		t.Run("should not diff the header "+mode.name, func(t *testing.T) {
+			got, err := digest.Diff(mode.config(config(base)), mode.config(config(delta)))
+
+			assert.NoError(t, err)
+			assert.Empty(t, got.Additions)
+			assert.Empty(t, got.Deletions)
+			assert.Equal(t, 1, len(got.Modifications))
+			assert.Equal(t, []string{"2", "zwei"}, got.Modifications[0].Current)
+			assert.Equal(t, digest.Position{Line: 3, Offset: 16}, got.Modifications[0].CurrentPosition)
+		})
+	}
+
+	t.Run("should not count the header without a key", func(t *testing.T) {
+		got, err := digest.DiffCounts(config(base), config(delta))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []digest.RowCount{{Row: []string{"2", "zwei"}, Count: 1, Position: digest.Position{Line: 3, Offset: 16}}}, got.Additions)
+		assert.Equal(t, []digest.RowCount{{Row: []string{"2", "two"}, Count: 1, Position: digest.Position{Line: 3, Offset: 14}}}, got.Deletions)
+	})
+}
+
+func TestDiffChangedColumns(t *testing.T) {
+	base := `id,name,role,team
+1,tom,developer,core
+2,ryan,qa,core
+`
+	delta := `id,name,role,team
+1,thomas,lead,core
+2,ryan,qa-lead,core
+`
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Value: []int{1, 3}, Separator: ','}
+	}
+
+	t.Run("should tell compared columns from the others", func(t *testing.T) {
+		got, err := digest.Diff(config(base), config(delta))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(got.Modifications))
+		assert.Equal(t, []digest.ColumnChange{
+			{Index: 1, Compared: true},
+			{Index: 2, Compared: false},
+		}, got.Modifications[0].Columns)
+	})
+
+	t.Run("should name the columns after the header", func(t *testing.T) {
+		baseConfig := config(base)
+		baseConfig.Header = []string{"id", "name", "role", "team"}
+		got, err := digest.Diff(baseConfig, config(delta))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []digest.ColumnChange{
+			{Index: 1, Name: "name", Compared: true},
+			{Index: 2, Name: "role", Compared: false},
+		}, got.Modifications[0].Columns)
+	})
+}
+
+func TestDiffers(t *testing.T) {
+	base := "1,one\n2,two\n3,three\n"
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ','}
+	}
+
+	testCases := []struct {
+		name    string
+		delta   string
+		differs bool
+	}{
+		{name: "same rows", delta: base, differs: false},
+		{name: "same rows with a duplicate key", delta: base + "3,three\n", differs: false},
+		{name: "an added row", delta: base + "4,four\n", differs: true},
+		{name: "a modified row", delta: "1,one\n2,zwei\n3,three\n", differs: true},
+		{name: "a deleted row", delta: "1,one\n2,two\n", differs: true},
+	}
+	modes := []struct {
+		name   string
+		config func(digest.Config) digest.Config
+	}{
+		{"in memory", func(c digest.Config) digest.Config { return c }},
+		{"on disk", func(c digest.Config) digest.Config { c.MaxMemory = 1; return c }},
+		{"sorted", func(c digest.Config) digest.Config { c.Sorted = true; return c }},
+	}
+	for _, mode := range modes {
+		for _, tt := range testCases {
+			t.Run(fmt.Sprintf("should tell if %s differ %s", tt.name, mode.name), func(t *testing.T) {
+				baseConfig := mode.config(config(base))
+				differs, err := digest.Differs(baseConfig, config(tt.delta))
+
+				assert.NoError(t, err)
+				assert.Equal(t, tt.differs, differs)
+			})
+		}
+	}
+
+	t.Run("should not count a row in the same place as a move", func(t *testing.T) {
+		baseConfig := config(base)
+		baseConfig.Reorders = true
+		differs, err := digest.Differs(baseConfig, config(base))
+
+		assert.NoError(t, err)
+		assert.False(t, differs)
+	})
+}
+
+func TestDiffUnchanged(t *testing.T) {
+	base := "1,one\n2,two\n3,three\n"
+	delta := "1,one\n2,zwei\n3,three\n4,four\n"
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ','}
+	}
+
+	t.Run("should count unchanged rows if asked", func(t *testing.T) {
+		baseConfig := config(base)
+		baseConfig.Unchanged = true
+		diff, err := digest.Diff(baseConfig, config(delta))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, diff.Unchanged)
+		assert.Len(t, diff.Modifications, 1)
+		assert.Len(t, diff.Additions, 1)
+	})
+
+	t.Run("should not count unchanged rows otherwise", func(t *testing.T) {
+		diff, err := digest.Diff(config(base), config(delta))
+
+		assert.NoError(t, err)
+		assert.Zero(t, diff.Unchanged)
+	})
+}
@@ -0,0 +1,97 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamDifferencesVerify(t *testing.T) {
+	// Hashes are made up so that both a key and a value collide
+	base := func() *FileDigest {
+		fd := NewFileDigest()
+		fd.Append(Digest{Key: 1, Value: 10, Source: []string{"a", "x"}, Line: 1})
+		fd.Append(Digest{Key: 2, Value: 20, Source: []string{"c", "1"}, Line: 2})
+		return fd
+	}
+	delta := []Digest{
+		{Key: 1, Value: 11, Source: []string{"b", "y"}, Line: 1},
+		{Key: 2, Value: 20, Source: []string{"c", "2"}, Line: 2},
+	}
+	collect := func(fd *FileDigest, config Config) []message {
+		digestChannel := make(chan []Digest, 1)
+		digestChannel <- delta
+		close(digestChannel)
+
+		var messages []message
+		for msg := range streamDifferences(context.Background(), fd, digestChannel, config) {
+			messages = append(messages, msg)
+		}
+		return messages
+	}
+
+	t.Run("should trust hashes by default", func(t *testing.T) {
+		messages := collect(base(), Config{Key: []int{0}})
+
+		assert.Equal(t, []message{
+			{_type: modification, original: Digest{Key: 1, Value: 10, Source: []string{"a", "x"}, Line: 1}, current: delta[0]},
+		}, messages)
+	})
+
+	t.Run("should confirm hash matches against the cells", func(t *testing.T) {
+		messages := collect(base(), Config{Key: []int{0}, Verify: true})
+
+		assert.Equal(t, []message{
+			{_type: addition, current: delta[0]},
+			{_type: modification, original: Digest{Key: 2, Value: 20, Source: []string{"c", "1"}, Line: 2}, current: delta[1]},
+			{_type: deletion, current: Digest{Key: 1, Value: 10, Source: []string{"a", "x"}, Line: 1}},
+		}, messages)
+	})
+}
+
+func TestCollisionIn(t *testing.T) {
+	fd := NewFileDigest()
+	fd.Append(Digest{Key: 1, Source: []string{"a", "x"}, Line: 1})
+	fd.Append(Digest{Key: 1, Source: []string{"b", "y"}, Line: 2})
+
+	err := collisionIn(Base, Positions{0}, fd.Duplicates)
+
+	assert.EqualError(t, err, `primary keys "a" on line 1 and "b" on line 2 of base file have the same hash`)
+	assert.Nil(t, duplicatesIn(Base, Positions{0}, fd.Duplicates))
+}
+
+func TestDiffInMemoryPrefetch(t *testing.T) {
+	var rows strings.Builder
+	for i := 0; i < 100*bufferSize; i++ {
+		fmt.Fprintf(&rows, "%d,%d\n", i, i)
+	}
+	baseReader, baseWriter := io.Pipe()
+	var deltaRows int64
+	baseConfig := Config{Reader: baseReader, Key: []int{0}, Separator: ',', Progress: func(p Progress) {
+		atomic.StoreInt64(&deltaRows, p.Delta.Rows)
+	}}
+	deltaConfig := Config{Reader: strings.NewReader(rows.String()), Key: []int{0}, Separator: ','}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- diffInMemory(context.Background(), baseConfig, deltaConfig, newProgressTracker(baseConfig.Progress), func(message) {})
+	}()
+
+	// wait for reading delta to pause while base is not read
+	var read int64
+	for read == 0 || read != atomic.LoadInt64(&deltaRows) {
+		read = atomic.LoadInt64(&deltaRows)
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.True(t, read <= int64((deltaPrefetch+runtime.NumCPU()+1)*bufferSize))
+
+	assert.NoError(t, baseWriter.Close())
+	assert.NoError(t, <-done)
+}
@@ -8,19 +8,29 @@ import (
 	"github.com/cespare/xxhash"
 )
 
+// HashAlgorithm is the hash Digest keys and values are created with
+const HashAlgorithm = "xxhash64"
+
 // Digest represents the binding of the key of each csv line
-// and the digest that gets created for the entire line
+// and the digest that gets created for the entire line.
+// Line and Offset are the line number and byte offset
+// the row starts at in its file.
 type Digest struct {
 	Key    uint64
 	Value  uint64
 	Source []string
+	Line   int
+	Offset int64
 }
 
 // CreateDigest creates a Digest for each line of csv.
 // There will be one Digest per line
+//
+// Separators inside the values are escaped before hashing so that
+// the key ("a,b", "c") does not collide with the key ("a", "b,c").
 func CreateDigest(csv []string, separator string, pKey Positions, pRow Positions) Digest {
-	key := xxhash.Sum64String(pKey.Join(csv, separator))
-	digest := xxhash.Sum64String(pRow.Join(csv, separator))
+	key := xxhash.Sum64String(pKey.encode(csv, separator))
+	digest := xxhash.Sum64String(pRow.encode(csv, separator))
 
 	return Digest{Key: key, Value: digest, Source: csv}
 }
@@ -81,7 +91,7 @@ func readAndProcess(config *Config, reader *csv.Reader, digestChannel chan<- []D
 	errorChannel <- nil
 }
 
-func createDigestForNLines(lines [][]string,
+func createDigestForNLines(lines []record,
 	config *Config,
 	digestChannel chan<- []Digest,
 	wg *sync.WaitGroup,
@@ -89,7 +99,9 @@ func createDigestForNLines(lines [][]string,
 	output := make([]Digest, len(lines))
 	separator := string(config.Separator)
 	for i, line := range lines {
-		output[i] = CreateDigest(line, separator, config.Key, config.Value)
+		output[i] = CreateDigest(line.fields, separator, config.Key, config.Value)
+		output[i].Line = line.line
+		output[i].Offset = line.offset
 	}
 
 	digestChannel <- output
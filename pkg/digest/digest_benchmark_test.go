@@ -1,9 +1,14 @@
 package digest
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"runtime"
+	"strings"
 	"testing"
+
+	"github.com/cespare/xxhash"
 )
 
 const SomeText = "something-name-%d,346345ty,fdhfdh,5436456,gfgjfgj,45234545,nfhgjfgj,45745745,djhgfjfgj"
@@ -18,6 +23,94 @@ func BenchmarkCreate100000(b *testing.B)   { benchmarkCreate(100000, b) }
 func BenchmarkCreate1000000(b *testing.B)  { benchmarkCreate(1000000, b) }
 func BenchmarkCreate10000000(b *testing.B) { benchmarkCreate(10000000, b) }
 
+func BenchmarkCreateDigest(b *testing.B) {
+	line := strings.Split(fmt.Sprintf("%d,%s", 1, SomeText), ",")
+	key, value := Positions{0, 1}, Positions{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CreateDigest(line, ",", key, value)
+	}
+}
+
+// BenchmarkCreateDigestJoined hashes the plainly joined values
+// as a baseline for the cost of escaping in BenchmarkCreateDigest
+func BenchmarkCreateDigestJoined(b *testing.B) {
+	line := strings.Split(fmt.Sprintf("%d,%s", 1, SomeText), ",")
+	key, value := Positions{0, 1}, Positions{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = Digest{
+			Key:    xxhash.Sum64String(key.Join(line, ",")),
+			Value:  xxhash.Sum64String(value.Join(line, ",")),
+			Source: line,
+		}
+	}
+}
+
+func BenchmarkDiff(b *testing.B) { benchmarkDiff(100000, b) }
+
+func benchmarkDiff(count int, b *testing.B) {
+	base, delta := diffInputs(count)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		baseConfig := Config{Reader: strings.NewReader(base), Key: []int{0}, Separator: ','}
+		deltaConfig := Config{Reader: strings.NewReader(delta), Key: []int{0}, Separator: ','}
+		err := diffInMemory(context.Background(), baseConfig, deltaConfig, nil, func(message) {})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDiffHeap and BenchmarkDiffIndexHeap report the bytes
+// of heap a base row takes once base is read
+func BenchmarkDiffHeap(b *testing.B)      { benchmarkDiffHeap(100000, false, b) }
+func BenchmarkDiffIndexHeap(b *testing.B) { benchmarkDiffHeap(100000, true, b) }
+
+func benchmarkDiffHeap(count int, index bool, b *testing.B) {
+	base, delta := diffInputs(count)
+
+	var before, after runtime.MemStats
+	progress := func(p Progress) {
+		if p.Phase == ReadingDelta && after.HeapAlloc == 0 {
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+		}
+	}
+
+	for i := 0; i < b.N; i++ {
+		after = runtime.MemStats{}
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		baseConfig := Config{Reader: strings.NewReader(base), Key: []int{0}, Separator: ',', Index: index, Progress: progress}
+		deltaConfig := Config{Reader: strings.NewReader(delta), Key: []int{0}, Separator: ','}
+		if _, err := Diff(baseConfig, deltaConfig); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(count), "heap-B/row")
+}
+
+// diffInputs returns a base and a delta of count rows
+// where every tenth row is modified
+func diffInputs(count int) (string, string) {
+	var base, delta strings.Builder
+	for i := 0; i < count; i++ {
+		row := fmt.Sprintf("%d,%s\n", i, SomeText)
+		base.WriteString(row)
+		if i%10 == 0 {
+			row = fmt.Sprintf("%d,%s-modified\n", i, SomeText)
+		}
+		delta.WriteString(row)
+	}
+	return base.String(), delta.String()
+}
+
 func benchmarkCreate(limit int, b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		CreateDigestFor(limit, b)
@@ -26,6 +26,37 @@ func TestCreateDigestWithSource(t *testing.T) {
 	assert.Equal(t, expectedDigest, actualDigest)
 }
 
+func TestCreateDigestEncoding(t *testing.T) {
+	t.Run("should not collide keys moving a separator between values", func(t *testing.T) {
+		first := digest.CreateDigest([]string{"a,b", "c"}, comma, []int{0, 1}, []int{})
+		second := digest.CreateDigest([]string{"a", "b,c"}, comma, []int{0, 1}, []int{})
+
+		assert.NotEqual(t, first.Key, second.Key)
+		assert.NotEqual(t, first.Value, second.Value)
+	})
+
+	t.Run("should not collide values escaping the separator", func(t *testing.T) {
+		first := digest.CreateDigest([]string{`a\`, "b"}, comma, []int{0}, []int{})
+		second := digest.CreateDigest([]string{`a\,b`}, comma, []int{0}, []int{})
+
+		assert.NotEqual(t, first.Value, second.Value)
+	})
+
+	t.Run("should hash values without separators as joined", func(t *testing.T) {
+		d := digest.CreateDigest([]string{"1", "a", "b"}, comma, []int{0, 1}, []int{})
+
+		assert.Equal(t, xxhash.Sum64String("1,a"), d.Key)
+		assert.Equal(t, xxhash.Sum64String("1,a,b"), d.Value)
+	})
+
+	t.Run("should escape with another character if separator is a backslash", func(t *testing.T) {
+		first := digest.CreateDigest([]string{`a\b`, "c"}, `\`, []int{0, 1}, []int{})
+		second := digest.CreateDigest([]string{"a", `b\c`}, `\`, []int{0, 1}, []int{})
+
+		assert.NotEqual(t, first.Key, second.Key)
+	})
+}
+
 func TestDigestForFile(t *testing.T) {
 	firstLine := "1,first-line,some-columne,friday"
 	firstKey := xxhash.Sum64String("1")
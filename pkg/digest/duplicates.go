@@ -0,0 +1,208 @@
+package digest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DuplicatePolicy decides how rows sharing a primary key are diffed
+type DuplicatePolicy int
+
+const (
+	// DuplicateWarn reports duplicate keys in Differences.
+	// Every row of a duplicate key is diffed on its own in both
+	// files. A delta row is diffed against each base row of its key
+	// and each base row of a key missing from delta is deleted.
+	DuplicateWarn DuplicatePolicy = iota
+	// DuplicateFail makes Diff return a *DuplicateKeyError.
+	DuplicateFail
+	// DuplicateMultiset diffs rows sharing a key as a multiset.
+	// Identical rows cancel each other out. The remaining rows
+	// are paired up in line order as modifications and the
+	// surplus is reported as additions or deletions.
+	DuplicateMultiset
+)
+
+// Duplicate is a primary key found on more than one line of a file
+type Duplicate struct {
+	File  File
+	Key   []string
+	Lines []int
+}
+
+// DuplicateKeyError is returned by Diff for the DuplicateFail policy
+type DuplicateKeyError struct {
+	Duplicate
+}
+
+func (e *DuplicateKeyError) Error() string {
+	lines := make([]string, 0, len(e.Lines))
+	for _, line := range e.Lines {
+		lines = append(lines, strconv.Itoa(line))
+	}
+
+	return fmt.Sprintf("duplicate primary key %q on lines %s of %s file",
+		strings.Join(e.Key, ","), strings.Join(lines, ", "), e.File)
+}
+
+// duplicateTracker finds the keys repeated in delta. Like the
+// deltaDuplicates of an index, keys found in base keep the line they
+// were first seen on next to their row in base, so only the keys
+// that are not in base are held here.
+type duplicateTracker struct {
+	key        Positions
+	base       *FileDigest
+	added      map[uint64]int
+	duplicates map[uint64]*Duplicate
+}
+
+func newDuplicateTracker(base *FileDigest, key Positions) *duplicateTracker {
+	return &duplicateTracker{
+		key:        key,
+		base:       base,
+		added:      make(map[uint64]int),
+		duplicates: make(map[uint64]*Duplicate),
+	}
+}
+
+// add tracks d and returns the line its key was first seen on in delta
+func (t *duplicateTracker) add(d Digest) int {
+	firstLine, present := t.base.markDelta(d)
+	if !present {
+		var seen bool
+		if firstLine, seen = t.added[d.Key]; !seen {
+			t.added[d.Key] = d.Line
+			return d.Line
+		}
+	}
+	if firstLine == d.Line {
+		return firstLine
+	}
+
+	if duplicate, found := t.duplicates[d.Key]; found {
+		duplicate.Lines = append(duplicate.Lines, d.Line)
+		return firstLine
+	}
+
+	t.duplicates[d.Key] = &Duplicate{File: Delta, Key: t.key.pluck(d.Source), Lines: []int{firstLine, d.Line}}
+	return firstLine
+}
+
+// consumed keeps tracking the key of d once its last row is
+// consumed from base. firstLine is the line add returned for d.
+func (t *duplicateTracker) consumed(d Digest, firstLine int) {
+	if _, present := t.base.positions[d.Key]; !present {
+		t.added[d.Key] = firstLine
+	}
+}
+
+// seen tells if a key of base was seen in delta
+func (t *duplicateTracker) seen(key uint64) bool {
+	return t.base.positions[key].deltaLine != 0
+}
+
+// found returns the duplicates ordered by the line they first appear on.
+// It returns nil if no key was repeated.
+func (t *duplicateTracker) found() []Duplicate {
+	var duplicates []Duplicate
+	for _, duplicate := range t.duplicates {
+		sort.Ints(duplicate.Lines)
+		duplicates = append(duplicates, *duplicate)
+	}
+	sortDuplicates(duplicates)
+
+	return duplicates
+}
+
+// duplicatesIn converts the duplicate occurrences recorded by a FileDigest.
+// Rows are told apart by their key cells so that different keys
+// sharing a hash are not reported. It returns nil if there are none.
+func duplicatesIn(file File, key Positions, occurrences map[uint64][]Digest) []Duplicate {
+	var duplicates []Duplicate
+	for _, rows := range occurrences {
+		for _, group := range groupByKey(key, rows) {
+			if len(group) < 2 {
+				continue
+			}
+
+			lines := make([]int, 0, len(group))
+			for _, row := range group {
+				lines = append(lines, row.Line)
+			}
+			duplicates = append(duplicates, Duplicate{File: file, Key: key.pluck(group[0].Source), Lines: lines})
+		}
+	}
+	sortDuplicates(duplicates)
+
+	return duplicates
+}
+
+// HashCollisionError is returned by Diff when verifying finds
+// two different primary keys of the same file sharing a hash
+type HashCollisionError struct {
+	File  File
+	Keys  [2][]string
+	Lines [2]int
+}
+
+func (e *HashCollisionError) Error() string {
+	return fmt.Sprintf("primary keys %q on line %d and %q on line %d of %s file have the same hash",
+		strings.Join(e.Keys[0], ","), e.Lines[0], strings.Join(e.Keys[1], ","), e.Lines[1], e.File)
+}
+
+// collisionIn finds the first pair of different keys sharing a hash
+// among the duplicate occurrences recorded by a FileDigest.
+func collisionIn(file File, key Positions, occurrences map[uint64][]Digest) *HashCollisionError {
+	var collision *HashCollisionError
+	for _, rows := range occurrences {
+		groups := groupByKey(key, rows)
+		if len(groups) < 2 {
+			continue
+		}
+
+		first, second := groups[0][0], groups[1][0]
+		if collision == nil || first.Line < collision.Lines[0] {
+			collision = &HashCollisionError{
+				File:  file,
+				Keys:  [2][]string{key.pluck(first.Source), key.pluck(second.Source)},
+				Lines: [2]int{first.Line, second.Line},
+			}
+		}
+	}
+
+	return collision
+}
+
+// groupByKey splits rows sharing a key hash by their key cells.
+// Groups are in the line order of their first row.
+func groupByKey(key Positions, rows []Digest) [][]Digest {
+	sortByLine(rows)
+
+	index := make(map[string]int)
+	groups := make([][]Digest, 0, 1)
+	for _, row := range rows {
+		k := key.String(row.Source, ',')
+		i, found := index[k]
+		if !found {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], row)
+	}
+
+	return groups
+}
+
+// sortDuplicates orders duplicates of base before those
+// of delta, each by the line they first appear on.
+func sortDuplicates(duplicates []Duplicate) {
+	sort.Slice(duplicates, func(i, j int) bool {
+		if duplicates[i].File != duplicates[j].File {
+			return duplicates[i].File < duplicates[j].File
+		}
+		return duplicates[i].Lines[0] < duplicates[j].Lines[0]
+	})
+}
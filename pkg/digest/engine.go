@@ -1,6 +1,7 @@
 package digest
 
 import (
+	"context"
 	"encoding/csv"
 	"runtime"
 	"sync"
@@ -10,6 +11,12 @@ import (
 type Engine struct {
 	config Config
 	lock   *sync.Mutex
+	// onRead is called with each batch of rows read
+	// and the bytes read so far, if set
+	onRead func(rows int, bytes int64)
+	// batches is how many batches of digests are buffered
+	// in the digestChannel, bufferSize per CPU if not set
+	batches int
 }
 
 // NewEngine instantiates an engine
@@ -52,28 +59,59 @@ func (e Engine) GenerateFileDigest() (*FileDigest, error) {
 // to creates digests are waited to be closed and the digestChannel is closed at the end.
 // Only after that an error is created on the errorChannel.
 func (e Engine) StreamDigests() (chan []Digest, chan error) {
+	return e.StreamDigestsContext(context.Background())
+}
+
+// StreamDigestsContext is StreamDigests stopping once ctx is done.
+// All go routines exit even if digestChannel is no longer read,
+// and the errorChannel receives the error of ctx.
+//
+// At most one batch of rows per CPU is digested at a time. If digestChannel
+// is not read, reading stops once its buffer is full, so a file is never
+// held in memory beyond the buffer.
+func (e Engine) StreamDigestsContext(ctx context.Context) (chan []Digest, chan error) {
 	maxProcs := runtime.NumCPU()
-	digestChannel := make(chan []Digest, bufferSize*maxProcs)
+	batches := e.batches
+	if batches == 0 {
+		batches = bufferSize * maxProcs
+	}
+	digestChannel := make(chan []Digest, batches)
 	errorChannel := make(chan error, 1)
 
 	go func(digestChannel chan []Digest, errorChannel chan error) {
 		wg := &sync.WaitGroup{}
+		workers := make(chan struct{}, maxProcs)
 		reader := csv.NewReader(e.config.Reader)
 		reader.Comma = e.config.Separator
 		reader.LazyQuotes = e.config.LazyQuotes
-		for {
-			lines, eofReached, err := getNextNLines(reader)
 
+		err := skipHeader(reader, e.config)
+		for err == nil {
+			if err = ctx.Err(); err != nil {
+				break
+			}
+
+			var lines []record
+			var eofReached bool
+			lines, eofReached, err = getNextNLines(reader)
 			if err != nil {
-				wg.Wait()
-				close(digestChannel)
-				errorChannel <- err
-				close(errorChannel)
-				return
+				break
+			}
+			if e.onRead != nil {
+				e.onRead(len(lines), reader.InputOffset())
 			}
 
+			select {
+			case workers <- struct{}{}:
+			case <-ctx.Done():
+				err = ctx.Err()
+				continue
+			}
 			wg.Add(1)
-			go e.digestForLines(lines, digestChannel, wg)
+			go func(lines []record) {
+				defer func() { <-workers }()
+				e.digestForLines(ctx, lines, digestChannel, wg)
+			}(lines)
 
 			if eofReached {
 				break
@@ -81,7 +119,10 @@ func (e Engine) StreamDigests() (chan []Digest, chan error) {
 		}
 		wg.Wait()
 		close(digestChannel)
-		errorChannel <- nil
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		errorChannel <- err
 		close(errorChannel)
 	}(digestChannel, errorChannel)
 
@@ -89,13 +130,23 @@ func (e Engine) StreamDigests() (chan []Digest, chan error) {
 
 }
 
-func (e Engine) digestForLines(lines [][]string, digestChannel chan []Digest, wg *sync.WaitGroup) {
+func (e Engine) digestForLines(ctx context.Context, lines []record, digestChannel chan []Digest, wg *sync.WaitGroup) {
+	defer wg.Done()
+
 	output := make([]Digest, 0, len(lines))
 	separator := string(e.config.Separator)
 	for _, line := range lines {
-		output = append(output, CreateDigest(line, separator, e.config.Key, e.config.Value))
+		d := CreateDigest(line.fields, separator, e.config.Key, e.config.Value)
+		if !e.config.sampled(d.Key) {
+			continue
+		}
+		d.Line = line.line
+		d.Offset = line.offset
+		output = append(output, d)
 	}
 
-	digestChannel <- output
-	wg.Done()
+	select {
+	case digestChannel <- output:
+	case <-ctx.Done():
+	}
 }
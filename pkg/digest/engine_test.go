@@ -1,9 +1,13 @@
 package digest_test
 
 import (
+	"context"
 	"encoding/csv"
+	"fmt"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aswinkarthik/csvdiff/pkg/digest"
 	"github.com/cespare/xxhash"
@@ -37,8 +41,8 @@ func TestEngine_GenerateFileDigest(t *testing.T) {
 
 		actualDigest := digestsFrom(dChan)
 		expectedDigest := []digest.Digest{
-			{Key: firstKey, Value: firstDigest, Source: strings.Split(firstLine, ",")},
-			{Key: secondKey, Value: secondDigest, Source: strings.Split(secondLine, ",")},
+			{Key: firstKey, Value: firstDigest, Source: strings.Split(firstLine, ","), Line: 1},
+			{Key: secondKey, Value: secondDigest, Source: strings.Split(secondLine, ","), Line: 2, Offset: int64(len(firstLine) + 1)},
 		}
 
 		assert.ElementsMatch(t, expectedDigest, actualDigest)
@@ -60,8 +64,8 @@ func TestEngine_GenerateFileDigest(t *testing.T) {
 
 		actualDigest := digestsFrom(dChan)
 		expectedDigest := []digest.Digest{
-			{Key: firstKey, Value: firstDigest, Source: strings.Split(firstLine, ",")},
-			{Key: secondKey, Value: secondDigest, Source: strings.Split(secondLine, ",")},
+			{Key: firstKey, Value: firstDigest, Source: strings.Split(firstLine, ","), Line: 1},
+			{Key: secondKey, Value: secondDigest, Source: strings.Split(secondLine, ","), Line: 2, Offset: int64(len(firstLine) + 1)},
 		}
 
 		assert.ElementsMatch(t, expectedDigest, actualDigest)
@@ -84,8 +88,8 @@ func TestEngine_GenerateFileDigest(t *testing.T) {
 
 		actualDigest := digestsFrom(dChan)
 		expectedDigest := []digest.Digest{
-			{Key: firstKey, Value: fridayDigest, Source: strings.Split(firstLine, ",")},
-			{Key: secondKey, Value: saturdayDigest, Source: strings.Split(secondLine, ",")},
+			{Key: firstKey, Value: fridayDigest, Source: strings.Split(firstLine, ","), Line: 1},
+			{Key: secondKey, Value: saturdayDigest, Source: strings.Split(secondLine, ","), Line: 2, Offset: int64(len(firstLine) + 1)},
 		}
 
 		assert.ElementsMatch(t, expectedDigest, actualDigest)
@@ -116,6 +120,54 @@ func TestEngine_GenerateFileDigest(t *testing.T) {
 	})
 }
 
+func TestEngine_StreamDigestsContext(t *testing.T) {
+	// an endless file, so the engine cannot be done before it is cancelled
+	conf := digest.Config{
+		Reader:    &endlessReader{},
+		Key:       []int{0},
+		Separator: ',',
+	}
+	goroutines := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dChan, eChan := digest.NewEngine(conf).StreamDigestsContext(ctx)
+
+	// stop reading after the first batch
+	<-dChan
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-eChan)
+	assertNoLeaks(t, goroutines)
+}
+
+// endlessReader reads an endless csv
+type endlessReader struct {
+	row     int
+	pending []byte
+}
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		r.pending = []byte(fmt.Sprintf("%d,value-%d\n", r.row, r.row))
+		r.row++
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// assertNoLeaks waits for the go routines started
+// since there were count of them to exit
+func assertNoLeaks(t *testing.T, count int) {
+	// assert.Eventually runs its condition on a go routine of its own
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if runtime.NumGoroutine() <= count {
+			return
+		}
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), count, "go routines leaked")
+}
+
 func digestsFrom(digestChan chan []digest.Digest) []digest.Digest {
 	result := make([]digest.Digest, 0, 10)
 
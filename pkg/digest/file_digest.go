@@ -1,30 +1,60 @@
 package digest
 
 import (
+	"sort"
 	"sync"
 )
 
 // FileDigest represents the digests created from one file
+//
+// When a key appears on more than one line, the row on the last line
+// is kept in Digests and SourceMap and every occurrence of the key
+// is recorded in Duplicates.
 type FileDigest struct {
-	Digests   map[uint64]uint64
-	SourceMap map[uint64][]string
-	lock      *sync.Mutex
+	Digests    map[uint64]uint64
+	SourceMap  map[uint64][]string
+	Duplicates map[uint64][]Digest
+	positions  map[uint64]rowPosition
+	lock       *sync.Mutex
+}
+
+// rowPosition is where the row of a key is and the line the key
+// was first seen on in delta, or 0 if delta has not had it yet.
+// Lines are held like in an index to keep it as small as a Position.
+type rowPosition struct {
+	offset    int64
+	line      uint32
+	deltaLine uint32
 }
 
 // NewFileDigest to instantiate a new FileDigest
 func NewFileDigest() *FileDigest {
 	return &FileDigest{
-		Digests:   make(map[uint64]uint64),
-		SourceMap: make(map[uint64][]string),
-		lock:      &sync.Mutex{},
+		Digests:    make(map[uint64]uint64),
+		SourceMap:  make(map[uint64][]string),
+		Duplicates: make(map[uint64][]Digest),
+		positions:  make(map[uint64]rowPosition),
+		lock:       &sync.Mutex{},
 	}
 }
 
 // Append a Digest to a FileDigest
 // This operation is not thread safe
 func (f *FileDigest) Append(d Digest) {
+	if position, present := f.positions[d.Key]; present {
+		if _, seen := f.Duplicates[d.Key]; !seen {
+			f.Duplicates[d.Key] = []Digest{f.digest(d.Key)}
+		}
+		f.Duplicates[d.Key] = append(f.Duplicates[d.Key], d)
+
+		if d.Line < int(position.line) {
+			return
+		}
+	}
+
 	f.Digests[d.Key] = d.Value
 	f.SourceMap[d.Key] = d.Source
+	f.positions[d.Key] = rowPosition{offset: d.Offset, line: uint32(d.Line)}
 }
 
 // SafeAppend a Digest to a FileDigest
@@ -33,6 +63,91 @@ func (f *FileDigest) SafeAppend(d Digest) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
-	f.Digests[d.Key] = d.Value
-	f.SourceMap[d.Key] = d.Source
+	f.Append(d)
+}
+
+func (f *FileDigest) digest(key uint64) Digest {
+	position := f.positions[key]
+	return Digest{Key: key, Value: f.Digests[key], Source: f.SourceMap[key], Line: int(position.line), Offset: position.offset}
+}
+
+// rows returns every row with the given key in line order
+func (f *FileDigest) rows(key uint64) []Digest {
+	if rows, isDuplicate := f.Duplicates[key]; isDuplicate {
+		sortByLine(rows)
+		return rows
+	}
+
+	return []Digest{f.digest(key)}
+}
+
+// markDelta records d.Line as the first line of its key in delta.
+// It returns the first line recorded, which is d.Line unless the key
+// was seen in delta before, and false if base does not have the key.
+func (f *FileDigest) markDelta(d Digest) (int, bool) {
+	position, present := f.positions[d.Key]
+	if !present {
+		return 0, false
+	}
+	if position.deltaLine == 0 {
+		position.deltaLine = uint32(d.Line)
+		f.positions[d.Key] = position
+	}
+
+	return int(position.deltaLine), true
+}
+
+// consume removes and returns a row with the same key and value as d.
+// It returns false if there is no such row.
+func (f *FileDigest) consume(d Digest, config Config) (Digest, bool) {
+	same := func(original Digest) bool {
+		return config.sameKey(original.Source, d.Source) && config.sameValue(original, d)
+	}
+
+	occurrences, isDuplicate := f.Duplicates[d.Key]
+	if !isDuplicate {
+		if _, present := f.Digests[d.Key]; present && same(f.digest(d.Key)) {
+			original := f.digest(d.Key)
+			f.remove(d.Key)
+			return original, true
+		}
+		return Digest{}, false
+	}
+
+	for i, occurrence := range occurrences {
+		if same(occurrence) {
+			f.Duplicates[d.Key] = append(occurrences[:i], occurrences[i+1:]...)
+			if len(f.Duplicates[d.Key]) == 0 {
+				f.remove(d.Key)
+			}
+			return occurrence, true
+		}
+	}
+
+	return Digest{}, false
+}
+
+// take removes and returns all rows with the given key in line order.
+func (f *FileDigest) take(key uint64) []Digest {
+	if _, present := f.positions[key]; !present {
+		return nil
+	}
+
+	rows := f.rows(key)
+	f.remove(key)
+
+	return rows
+}
+
+func (f *FileDigest) remove(key uint64) {
+	delete(f.Digests, key)
+	delete(f.SourceMap, key)
+	delete(f.positions, key)
+	delete(f.Duplicates, key)
+}
+
+func sortByLine(digests []Digest) {
+	sort.Slice(digests, func(i, j int) bool {
+		return digests[i].Line < digests[j].Line
+	})
 }
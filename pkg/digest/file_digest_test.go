@@ -45,3 +45,18 @@ func TestFileDigest_SafeAppend(t *testing.T) {
 	assert.Len(t, fd.Digests, 1000)
 	assert.Len(t, fd.SourceMap, 1000)
 }
+
+func TestFileDigest_AppendDuplicate(t *testing.T) {
+	fd := digest.NewFileDigest()
+
+	fd.Append(digest.Digest{Key: uint64(1), Value: uint64(2), Source: []string{"1", "two"}, Line: 2})
+	fd.Append(digest.Digest{Key: uint64(1), Value: uint64(1), Source: []string{"1", "one"}, Line: 1})
+
+	assert.Len(t, fd.Digests, 1)
+	assert.Equal(t, uint64(2), fd.Digests[uint64(1)])
+	assert.Equal(t, []string{"1", "two"}, fd.SourceMap[uint64(1)])
+	assert.ElementsMatch(t, []digest.Digest{
+		{Key: uint64(1), Value: uint64(1), Source: []string{"1", "one"}, Line: 1},
+		{Key: uint64(1), Value: uint64(2), Source: []string{"1", "two"}, Line: 2},
+	}, fd.Duplicates[uint64(1)])
+}
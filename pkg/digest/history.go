@@ -0,0 +1,143 @@
+package digest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EventType tells how the row of a key changed in a series of files
+type EventType int
+
+const (
+	// Seen is a key in the first file or in a file
+	// that the file before did not have it in
+	Seen EventType = iota
+	// Modified is a row whose values changed since the file before
+	Modified
+	// Deleted is a key the file before had and this file does not
+	Deleted
+)
+
+// Event is a change to the row of a key in the file numbered File
+// of a series, counting from 0. Row is the row in that file, or the last
+// row of the key if it was deleted. Columns are the changed columns
+// of a modification, as in Modification.
+type Event struct {
+	Type    EventType
+	File    int
+	Row     []string
+	Columns []ColumnChange
+}
+
+// Timeline is the events of the row of a key, oldest first
+type Timeline struct {
+	Key    []string
+	Events []Event
+}
+
+// History diffs each file of a series against the file before and returns
+// the timelines of the keys whose row changed, ordered by key. Keys that
+// are in all files with the same values since their first file are left out.
+//
+// Each file is digested once and its digest is the base of the next diff,
+// so at most two files are held in memory. Key, Value, Separator and
+// LazyQuotes are taken from each config and Header from the first one.
+// Primary keys must be unique in each file.
+func History(configs []Config) ([]Timeline, error) {
+	timelines := make(map[uint64]*Timeline)
+	// since is the file each key in the file before was seen in last.
+	// Its row is the row of the key in the file before unless columns
+	// other than the compared ones changed since, which keeps it in seen.
+	since := make(map[uint64]int)
+	seen := make(map[uint64][]string)
+	event := func(key uint64, e Event, previous []string) {
+		timeline, present := timelines[key]
+		if !present {
+			timeline = &Timeline{Key: configs[0].Key.pluck(e.Row)}
+			if e.Type != Seen {
+				if row, kept := seen[key]; kept {
+					previous = row
+				}
+				timeline.Events = append(timeline.Events, Event{Type: Seen, File: since[key], Row: previous})
+			}
+			timelines[key] = timeline
+		}
+		delete(seen, key)
+		timeline.Events = append(timeline.Events, e)
+	}
+
+	var previous *FileDigest
+	for i, config := range configs {
+		current, err := uniqueDigest(config, i)
+		if err != nil {
+			return nil, err
+		}
+
+		for key := range current.Digests {
+			row := current.digest(key)
+			if previous == nil {
+				since[key] = i
+				continue
+			}
+			if _, present := previous.Digests[key]; !present {
+				since[key] = i
+				event(key, Event{Type: Seen, File: i, Row: row.Source}, nil)
+				continue
+			}
+			original := previous.digest(key)
+			if !config.sameValue(original, row) {
+				columns := message{original: original, current: row}.changedColumns(configs[0])
+				event(key, Event{Type: Modified, File: i, Row: row.Source, Columns: columns}, original.Source)
+				continue
+			}
+			// only the compared columns of the row are known to be the same
+			if _, tracked := timelines[key]; !tracked && len(config.Value) > 0 {
+				if _, kept := seen[key]; !kept && !Positions(nil).equal(original.Source, row.Source) {
+					seen[key] = original.Source
+				}
+			}
+		}
+		if previous != nil {
+			for key, row := range previous.SourceMap {
+				if _, present := current.Digests[key]; !present {
+					event(key, Event{Type: Deleted, File: i, Row: row}, row)
+					delete(since, key)
+				}
+			}
+		}
+
+		previous = current
+	}
+
+	history := make([]Timeline, 0, len(timelines))
+	for _, timeline := range timelines {
+		history = append(history, *timeline)
+	}
+	sort.Slice(history, func(i, j int) bool { return compareKeys(history[i].Key, history[j].Key) < 0 })
+
+	return history, nil
+}
+
+// uniqueDigest digests the file numbered i of a series,
+// which may not repeat a key
+func uniqueDigest(config Config, i int) (*FileDigest, error) {
+	digest, err := NewEngine(config).GenerateFileDigest()
+	if err != nil {
+		return nil, fmt.Errorf("error processing file %d: %v", i+1, err)
+	}
+
+	var first []Digest
+	for _, rows := range digest.Duplicates {
+		sortByLine(rows)
+		if first == nil || rows[0].Line < first[0].Line {
+			first = rows
+		}
+	}
+	if first != nil {
+		return nil, fmt.Errorf("duplicate primary key %q on lines %d, %d of file %d. History needs unique keys",
+			strings.Join(config.Key.pluck(first[0].Source), ","), first[0].Line, first[1].Line, i+1)
+	}
+
+	return digest, nil
+}
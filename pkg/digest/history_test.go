@@ -0,0 +1,84 @@
+package digest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ','}
+	}
+	series := func(files ...string) []digest.Config {
+		configs := make([]digest.Config, 0, len(files))
+		for _, file := range files {
+			configs = append(configs, config(file))
+		}
+		configs[0].Header = []string{"id", "name", "age"}
+		return configs
+	}
+
+	t.Run("should follow each changed key across the files", func(t *testing.T) {
+		history, err := digest.History(series(
+			"id,name,age\n1,tom,2\n2,ryan,20\n3,emin,40\n",
+			"id,name,age\n1,tom,2\n2,ryan,21\n3,emin,40\n4,ana,30\n",
+			"id,name,age\n1,tom,2\n2,ryan,21\n4,ana,30\n",
+			"id,name,age\n1,tom,2\n2,ryan,22\n3,emin,41\n4,ana,30\n",
+		))
+
+		age := []digest.ColumnChange{{Index: 2, Name: "age", Compared: true}}
+		expected := []digest.Timeline{
+			{Key: []string{"2"}, Events: []digest.Event{
+				{Type: digest.Seen, File: 0, Row: []string{"2", "ryan", "20"}},
+				{Type: digest.Modified, File: 1, Row: []string{"2", "ryan", "21"}, Columns: age},
+				{Type: digest.Modified, File: 3, Row: []string{"2", "ryan", "22"}, Columns: age},
+			}},
+			{Key: []string{"3"}, Events: []digest.Event{
+				{Type: digest.Seen, File: 0, Row: []string{"3", "emin", "40"}},
+				{Type: digest.Deleted, File: 2, Row: []string{"3", "emin", "40"}},
+				{Type: digest.Seen, File: 3, Row: []string{"3", "emin", "41"}},
+			}},
+			{Key: []string{"4"}, Events: []digest.Event{
+				{Type: digest.Seen, File: 1, Row: []string{"4", "ana", "30"}},
+			}},
+		}
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, history)
+	})
+
+	t.Run("should show the row a key was first seen with", func(t *testing.T) {
+		configs := series(
+			"id,name,age\n1,tom,2\n",
+			"id,name,age\n1,thomas,2\n",
+			"id,name,age\n1,tommy,2\n",
+			"id,name,age\n1,tommy,3\n",
+		)
+		for i := range configs {
+			configs[i].Value = []int{2}
+		}
+		history, err := digest.History(configs)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []digest.Timeline{{Key: []string{"1"}, Events: []digest.Event{
+			{Type: digest.Seen, File: 0, Row: []string{"1", "tom", "2"}},
+			{Type: digest.Modified, File: 3, Row: []string{"1", "tommy", "3"}, Columns: []digest.ColumnChange{{Index: 2, Name: "age", Compared: true}}},
+		}}}, history)
+	})
+
+	t.Run("should leave out a series without changes", func(t *testing.T) {
+		history, err := digest.History(series("1,tom\n", "1,tom\n", "1,tom\n"))
+
+		assert.NoError(t, err)
+		assert.Empty(t, history)
+	})
+
+	t.Run("should need unique keys", func(t *testing.T) {
+		_, err := digest.History(series("1,tom\n", "1,tom\n2,ryan\n1,tim\n"))
+
+		assert.EqualError(t, err, `duplicate primary key "1" on lines 1, 3 of file 2. History needs unique keys`)
+	})
+}
@@ -0,0 +1,308 @@
+package digest
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// index holds the hashes, byte offset and line of every row of a
+// file without the row itself, sorted by key. A row takes 28 bytes.
+// Rows sharing a key are next to each other in line order.
+type index struct {
+	keys    []uint64
+	values  []uint64
+	offsets []int64
+	lines   []uint32
+}
+
+func (x *index) append(d Digest) {
+	x.keys = append(x.keys, d.Key)
+	x.values = append(x.values, d.Value)
+	x.offsets = append(x.offsets, d.Offset)
+	x.lines = append(x.lines, uint32(d.Line))
+}
+
+func (x *index) Len() int { return len(x.keys) }
+
+func (x *index) Less(i, j int) bool {
+	if x.keys[i] != x.keys[j] {
+		return x.keys[i] < x.keys[j]
+	}
+	return x.lines[i] < x.lines[j]
+}
+
+func (x *index) Swap(i, j int) {
+	x.keys[i], x.keys[j] = x.keys[j], x.keys[i]
+	x.values[i], x.values[j] = x.values[j], x.values[i]
+	x.offsets[i], x.offsets[j] = x.offsets[j], x.offsets[i]
+	x.lines[i], x.lines[j] = x.lines[j], x.lines[i]
+}
+
+// find returns the range of rows with the key. It is empty if there are none.
+func (x *index) find(key uint64) (int, int) {
+	first := sort.Search(len(x.keys), func(i int) bool { return x.keys[i] >= key })
+	last := first
+	for last < len(x.keys) && x.keys[last] == key {
+		last++
+	}
+	return first, last
+}
+
+// digest returns the row at i without its Source
+func (x *index) digest(i int) Digest {
+	return Digest{Key: x.keys[i], Value: x.values[i], Line: int(x.lines[i]), Offset: x.offsets[i]}
+}
+
+// bitset is a set of row numbers taking a bit a row
+type bitset []uint64
+
+func (b bitset) set(i int) { b[i/64] |= 1 << (uint(i) % 64) }
+
+func (b bitset) has(i int) bool { return b[i/64]&(1<<(uint(i)%64)) != 0 }
+
+// rowReader re-reads rows of a file by their byte offset
+type rowReader struct {
+	file       io.ReadSeeker
+	start      int64
+	separator  rune
+	lazyQuotes bool
+}
+
+// read returns d with the row it was created from as Source
+func (r rowReader) read(d Digest) (Digest, error) {
+	if _, err := r.file.Seek(r.start+d.Offset, io.SeekStart); err != nil {
+		return d, err
+	}
+
+	reader := csv.NewReader(r.file)
+	reader.Comma = r.separator
+	reader.LazyQuotes = r.lazyQuotes
+	row, err := reader.Read()
+	if err != nil {
+		return d, fmt.Errorf("error reading line %d again: %v", d.Line, unexpected(err))
+	}
+	d.Source = row
+
+	return d, nil
+}
+
+// deltaDuplicates finds the keys repeated in delta like a
+// duplicateTracker without holding every key of delta. Keys found in
+// base keep the line they were first seen on next to the index of base.
+// Added keys are kept in an index of their own, and the rows of added
+// keys that repeat are read again from delta for their key cells.
+type deltaDuplicates struct {
+	key        Positions
+	lines      []uint32
+	added      *index
+	duplicates map[uint64]*Duplicate
+}
+
+func newDeltaDuplicates(base *index, key Positions) *deltaDuplicates {
+	return &deltaDuplicates{
+		key:        key,
+		lines:      make([]uint32, base.Len()),
+		added:      &index{},
+		duplicates: make(map[uint64]*Duplicate),
+	}
+}
+
+// add tracks d, whose key has the rows first to last of base
+func (t *deltaDuplicates) add(d Digest, first, last int) {
+	if first == last {
+		t.added.append(d)
+		return
+	}
+	if t.lines[first] == 0 {
+		t.lines[first] = uint32(d.Line)
+		return
+	}
+
+	if duplicate, found := t.duplicates[d.Key]; found {
+		duplicate.Lines = append(duplicate.Lines, d.Line)
+		return
+	}
+	t.duplicates[d.Key] = &Duplicate{File: Delta, Key: t.key.pluck(d.Source), Lines: []int{int(t.lines[first]), d.Line}}
+}
+
+// found returns the duplicates ordered by the line they first appear on
+func (t *deltaDuplicates) found(rows rowReader) ([]Duplicate, error) {
+	var duplicates []Duplicate
+	for _, duplicate := range t.duplicates {
+		duplicates = append(duplicates, *duplicate)
+	}
+
+	sort.Sort(t.added)
+	for first := 0; first < t.added.Len(); {
+		last := first + 1
+		for last < t.added.Len() && t.added.keys[last] == t.added.keys[first] {
+			last++
+		}
+		if last-first > 1 {
+			d, err := rows.read(t.added.digest(first))
+			if err != nil {
+				return nil, err
+			}
+			lines := make([]int, 0, last-first)
+			for i := first; i < last; i++ {
+				lines = append(lines, int(t.added.lines[i]))
+			}
+			duplicates = append(duplicates, Duplicate{File: Delta, Key: t.key.pluck(d.Source), Lines: lines})
+		}
+		first = last
+	}
+
+	for _, duplicate := range duplicates {
+		sort.Ints(duplicate.Lines)
+	}
+	sortDuplicates(duplicates)
+
+	return duplicates, nil
+}
+
+// diffIndexed diffs like diffInMemory holding only an index of base.
+// Rows of base are read again for the modifications and deletions.
+// It falls back to diffInMemory if the base or delta Reader cannot seek.
+func diffIndexed(ctx context.Context, baseConfig, deltaConfig Config, progress *progressTracker, emit func(message)) error {
+	file, seekable := baseConfig.Reader.(io.ReadSeeker)
+	deltaFile, deltaSeekable := deltaConfig.Reader.(io.ReadSeeker)
+	if !seekable || !deltaSeekable {
+		return diffInMemory(ctx, baseConfig, deltaConfig, progress, emit)
+	}
+	if baseConfig.Verify || baseConfig.Duplicates == DuplicateMultiset {
+		return fmt.Errorf("an index of base cannot be verified or diffed as a multiset")
+	}
+	start, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("error processing base file: %v", err)
+	}
+	deltaStart, err := deltaFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("error processing delta file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	progress.phase(ReadingBase)
+	baseEngine := NewEngine(baseConfig)
+	baseEngine.onRead = progress.reader(Base)
+	baseDigestChannel, baseErrorChannel := baseEngine.StreamDigestsContext(ctx)
+
+	base := &index{}
+	for digests := range baseDigestChannel {
+		for _, d := range digests {
+			base.append(d)
+		}
+	}
+	if err := <-baseErrorChannel; err != nil {
+		return fmt.Errorf("error processing base file: %v", err)
+	}
+	sort.Sort(base)
+
+	rows := rowReader{file: file, start: start, separator: baseConfig.Separator, lazyQuotes: baseConfig.LazyQuotes}
+	if err := emitIndexDuplicates(base, rows, baseConfig, emit); err != nil {
+		return err
+	}
+
+	// Unlike diffInMemory, delta is read only now. Its digests
+	// would take more memory than the index while waiting for it.
+	progress.phase(ReadingDelta)
+	deltaEngine := NewEngine(deltaConfig)
+	deltaEngine.onRead = progress.reader(Delta)
+	deltaDigestChannel, deltaErrorChannel := deltaEngine.StreamDigestsContext(ctx)
+	seen := make(bitset, (base.Len()+63)/64)
+	deltaKeys := newDeltaDuplicates(base, baseConfig.Key)
+	var readErr error
+	for digests := range deltaDigestChannel {
+		for _, d := range digests {
+			if readErr != nil {
+				break
+			}
+
+			first, last := base.find(d.Key)
+			deltaKeys.add(d, first, last)
+			if first == last {
+				emit(message{_type: addition, current: d})
+				continue
+			}
+
+			// every row of a repeated base key is diffed on its own
+			for i := first; i < last && readErr == nil; i++ {
+				seen.set(i)
+				if base.values[i] != d.Value {
+					var original Digest
+					if original, readErr = rows.read(base.digest(i)); readErr == nil {
+						emit(message{_type: modification, current: d, original: original})
+					}
+				} else if baseConfig.sendsUnchanged() {
+					emit(message{_type: unchanged, current: d, original: base.digest(i)})
+				}
+			}
+		}
+		if readErr != nil {
+			cancel()
+		}
+	}
+	if readErr != nil {
+		return fmt.Errorf("error processing base file: %v", readErr)
+	}
+	if err := <-deltaErrorChannel; err != nil {
+		return fmt.Errorf("error processing delta file: %v", err)
+	}
+
+	// deletions are read in the order of their offsets to seek forward only
+	deletions := make([]Digest, 0)
+	for i := 0; i < base.Len(); i++ {
+		if !seen.has(i) {
+			deletions = append(deletions, base.digest(i))
+		}
+	}
+	sort.Slice(deletions, func(i, j int) bool { return deletions[i].Offset < deletions[j].Offset })
+	for _, d := range deletions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		deleted, err := rows.read(d)
+		if err != nil {
+			return fmt.Errorf("error processing base file: %v", err)
+		}
+		emit(message{_type: deletion, current: deleted})
+	}
+
+	deltaRows := rowReader{file: deltaFile, start: deltaStart, separator: deltaConfig.Separator, lazyQuotes: deltaConfig.LazyQuotes}
+	duplicates, err := deltaKeys.found(deltaRows)
+	if err != nil {
+		return fmt.Errorf("error processing delta file: %v", err)
+	}
+	for _, d := range duplicates {
+		emit(message{_type: duplicate, duplicate: d})
+	}
+
+	return checkDelta(duplicates, deltaConfig)
+}
+
+// emitIndexDuplicates reads the rows of the keys repeated in base
+// again and emits them like emitBase.
+func emitIndexDuplicates(base *index, rows rowReader, config Config, emit func(message)) error {
+	duplicates := make(map[uint64][]Digest)
+	for first := 0; first < base.Len(); {
+		last := first + 1
+		for last < base.Len() && base.keys[last] == base.keys[first] {
+			last++
+		}
+		for i := first; last-first > 1 && i < last; i++ {
+			d, err := rows.read(base.digest(i))
+			if err != nil {
+				return fmt.Errorf("error processing base file: %v", err)
+			}
+			duplicates[d.Key] = append(duplicates[d.Key], d)
+		}
+		first = last
+	}
+
+	return emitBase(&FileDigest{Duplicates: duplicates}, config, emit)
+}
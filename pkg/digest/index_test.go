@@ -0,0 +1,111 @@
+package digest_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffIndex(t *testing.T) {
+	base := `id,value
+1,one
+2,two
+2,two-again
+3,"three
+lines"
+4,four
+`
+	delta := `id,value
+1,one
+2,two-modified
+3,three
+5,five
+5,five-again
+`
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ','}
+	}
+
+	t.Run("should diff like in memory", func(t *testing.T) {
+		want, err := digest.Diff(config(base), config(delta))
+		assert.NoError(t, err)
+
+		baseConfig := config(base)
+		baseConfig.Index = true
+		got, err := digest.Diff(baseConfig, config(delta))
+
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+		assert.Equal(t, []string{"3", "three\nlines"}, got.Modifications[2].Original)
+	})
+
+	t.Run("should diff in memory if base cannot seek", func(t *testing.T) {
+		want, err := digest.Diff(config(base), config(delta))
+		assert.NoError(t, err)
+
+		baseConfig := config(base)
+		baseConfig.Reader, baseConfig.Index = struct{ io.Reader }{strings.NewReader(base)}, true
+		got, err := digest.Diff(baseConfig, config(delta))
+
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("should find the duplicates of delta like in memory", func(t *testing.T) {
+		delta := `id,value
+1,uno
+5,five
+1,one
+2,two
+5,"five
+again"
+1,eins
+`
+		want, err := digest.Diff(config(base), config(delta))
+		assert.NoError(t, err)
+
+		baseConfig := config(base)
+		baseConfig.Index = true
+		got, err := digest.Diff(baseConfig, config(delta))
+
+		assert.NoError(t, err)
+		assert.Equal(t, want.Duplicates, got.Duplicates)
+		assert.Equal(t, []digest.Duplicate{
+			{File: digest.Base, Key: []string{"2"}, Lines: []int{3, 4}},
+			{File: digest.Delta, Key: []string{"1"}, Lines: []int{2, 4, 8}},
+			{File: digest.Delta, Key: []string{"5"}, Lines: []int{3, 6}},
+		}, got.Duplicates)
+	})
+
+	t.Run("should diff in memory if delta cannot seek", func(t *testing.T) {
+		want, err := digest.Diff(config(base), config(delta))
+		assert.NoError(t, err)
+
+		baseConfig, deltaConfig := config(base), config(delta)
+		baseConfig.Index = true
+		deltaConfig.Reader = struct{ io.Reader }{strings.NewReader(delta)}
+		got, err := digest.Diff(baseConfig, deltaConfig)
+
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("should fail on duplicates in base", func(t *testing.T) {
+		baseConfig := config(base)
+		baseConfig.Index, baseConfig.Duplicates = true, digest.DuplicateFail
+		_, err := digest.Diff(baseConfig, config(delta))
+
+		assert.EqualError(t, err, `duplicate primary key "2" on lines 3, 4 of base file`)
+	})
+
+	t.Run("should not be verified", func(t *testing.T) {
+		baseConfig := config(base)
+		baseConfig.Index, baseConfig.Verify = true, true
+		_, err := digest.Diff(baseConfig, config(delta))
+
+		assert.EqualError(t, err, "an index of base cannot be verified or diffed as a multiset")
+	})
+}
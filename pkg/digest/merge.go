@@ -0,0 +1,220 @@
+package digest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Conflict is a row that ours and theirs changed in different ways.
+//
+// Base, Ours and Theirs are nil where the row is missing.
+// Columns are the cells changed differently. It is empty if one side
+// deleted the row and the other modified it. Row is the number of
+// the row in the merged file, counting from 1, or 0 if it was left out.
+type Conflict struct {
+	Key     []string
+	Base    []string
+	Ours    []string
+	Theirs  []string
+	Columns []int
+	Row     int
+}
+
+// Merge merges the rows ours and theirs changed since base by primary key
+// and writes the merged rows to w in the order of ours, followed by the
+// rows only theirs added. All three files are held in memory.
+//
+// A row changed on one side takes the change. A row changed on both sides
+// takes the changes to each cell. Cells changed differently are written like
+// "<<<<<<< ours ======= theirs >>>>>>>" and a row deleted on one side and
+// modified on the other is kept as modified. Both are returned as conflicts.
+//
+// Rows are compared as a whole. Key, Separator and LazyQuotes are taken
+// from each config and the rows are written with the separator of ours.
+func Merge(base, ours, theirs Config, w io.Writer) ([]Conflict, error) {
+	baseRows, err := uniqueRows(base, "base", "Merging")
+	if err != nil {
+		return nil, err
+	}
+	ourRows, err := uniqueRows(ours, "ours", "Merging")
+	if err != nil {
+		return nil, err
+	}
+	theirRows, err := uniqueRows(theirs, "theirs", "Merging")
+	if err != nil {
+		return nil, err
+	}
+
+	m := &merger{
+		writer: csv.NewWriter(w),
+		base:   byKey(baseRows),
+		theirs: byKey(theirRows),
+		merged: make(map[uint64]bool),
+	}
+	m.writer.Comma = ours.Separator
+	m.key = ours.Key
+
+	for _, o := range ourRows {
+		m.mergeOurs(o)
+	}
+	for _, t := range theirRows {
+		if !m.merged[t.Key] {
+			m.mergeTheirs(t)
+		}
+	}
+
+	m.writer.Flush()
+	if err := m.writer.Error(); err != nil {
+		return nil, fmt.Errorf("error writing merged rows: %v", err)
+	}
+
+	return m.conflicts, nil
+}
+
+// merger writes the merged rows and collects the conflicts
+type merger struct {
+	writer    *csv.Writer
+	key       Positions
+	base      map[uint64]Digest
+	theirs    map[uint64]Digest
+	merged    map[uint64]bool
+	rows      int
+	conflicts []Conflict
+}
+
+// mergeOurs merges a row of ours with base and theirs
+func (m *merger) mergeOurs(o Digest) {
+	m.merged[o.Key] = true
+	b, inBase := m.base[o.Key]
+	t, inTheirs := m.theirs[o.Key]
+
+	switch {
+	case !inBase && !inTheirs:
+		// added by ours
+		m.write(o.Source)
+	case !inBase:
+		// added by both
+		m.mergeCells(nil, &o, &t)
+	case !inTheirs:
+		// deleted by theirs
+		if o.Value != b.Value {
+			m.conflict(Conflict{Key: m.key.pluck(o.Source), Base: b.Source, Ours: o.Source}, o.Source)
+		}
+	case o.Value == b.Value:
+		m.write(t.Source)
+	default:
+		m.mergeCells(&b, &o, &t)
+	}
+}
+
+// mergeTheirs merges a row of theirs missing in ours with base
+func (m *merger) mergeTheirs(t Digest) {
+	b, inBase := m.base[t.Key]
+
+	switch {
+	case !inBase:
+		// added by theirs
+		m.write(t.Source)
+	case t.Value != b.Value:
+		// deleted by ours
+		m.conflict(Conflict{Key: m.key.pluck(t.Source), Base: b.Source, Theirs: t.Source}, t.Source)
+	}
+}
+
+// mergeCells merges the cells of a row both sides have.
+// base is nil if both sides added the row.
+func (m *merger) mergeCells(b, o, t *Digest) {
+	if o.Value == t.Value {
+		m.write(o.Source)
+		return
+	}
+	if b != nil && t.Value == b.Value {
+		m.write(o.Source)
+		return
+	}
+
+	cells := make([]string, len(o.Source))
+	var columns []int
+	for i := range cells {
+		ourCell, theirCell := o.Source[i], cell(t.Source, i)
+		switch {
+		case ourCell == theirCell:
+			cells[i] = ourCell
+		case b != nil && ourCell == cell(b.Source, i):
+			cells[i] = theirCell
+		case b != nil && theirCell == cell(b.Source, i):
+			cells[i] = ourCell
+		default:
+			cells[i] = fmt.Sprintf("<<<<<<< %s ======= %s >>>>>>>", ourCell, theirCell)
+			columns = append(columns, i)
+		}
+	}
+
+	if len(columns) == 0 {
+		m.write(cells)
+		return
+	}
+
+	conflict := Conflict{Key: m.key.pluck(o.Source), Ours: o.Source, Theirs: t.Source, Columns: columns}
+	if b != nil {
+		conflict.Base = b.Source
+	}
+	m.conflict(conflict, cells)
+}
+
+func (m *merger) write(row []string) {
+	m.rows++
+	// errors are sticky and surface on Flush
+	_ = m.writer.Write(row)
+}
+
+func (m *merger) conflict(c Conflict, row []string) {
+	m.write(row)
+	c.Row = m.rows
+	m.conflicts = append(m.conflicts, c)
+}
+
+// cell returns the cell of row at i or "" if row is shorter
+func cell(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+// uniqueRows digests all rows of the file of config in line order
+// for operation. The rows are compared as a whole and a key may not repeat.
+func uniqueRows(config Config, name, operation string) ([]Digest, error) {
+	config.Value = nil
+	digestChannel, errorChannel := NewEngine(config).StreamDigests()
+
+	rows := make([]Digest, 0)
+	for digests := range digestChannel {
+		rows = append(rows, digests...)
+	}
+	if err := <-errorChannel; err != nil {
+		return nil, fmt.Errorf("error processing %s file: %v", name, err)
+	}
+	sortByLine(rows)
+
+	lines := make(map[uint64]int, len(rows))
+	for _, row := range rows {
+		if line, present := lines[row.Key]; present {
+			return nil, fmt.Errorf("duplicate primary key %q on lines %d, %d of %s file. %s needs unique keys",
+				strings.Join(config.Key.pluck(row.Source), ","), line, row.Line, name, operation)
+		}
+		lines[row.Key] = row.Line
+	}
+
+	return rows, nil
+}
+
+func byKey(rows []Digest) map[uint64]Digest {
+	keyed := make(map[uint64]Digest, len(rows))
+	for _, row := range rows {
+		keyed[row.Key] = row
+	}
+	return keyed
+}
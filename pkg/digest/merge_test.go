@@ -0,0 +1,129 @@
+package digest_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ','}
+	}
+	merge := func(base, ours, theirs string) (string, []digest.Conflict, error) {
+		var merged bytes.Buffer
+		conflicts, err := digest.Merge(config(base), config(ours), config(theirs), &merged)
+		return merged.String(), conflicts, err
+	}
+
+	base := `id,name,role
+1,tom,developer
+2,ryan,qa
+3,emin,pm
+4,ana,ops
+`
+
+	t.Run("should take the changes of both sides", func(t *testing.T) {
+		ours := `id,name,role
+1,tom,lead
+2,ryan,qa
+4,ana,ops
+5,new,dev
+`
+		theirs := `id,name,role
+1,thomas,developer
+2,ryan,qa-lead
+3,emin,pm
+4,ana,ops
+6,other,dev
+`
+		merged, conflicts, err := merge(base, ours, theirs)
+
+		assert.NoError(t, err)
+		assert.Empty(t, conflicts)
+		assert.Equal(t, `id,name,role
+1,thomas,lead
+2,ryan,qa-lead
+4,ana,ops
+5,new,dev
+6,other,dev
+`, merged)
+	})
+
+	t.Run("should mark the cells changed differently", func(t *testing.T) {
+		ours := `id,name,role
+1,tom,lead
+2,ryan,qa
+3,emin,pm
+4,ana,ops
+5,new,dev
+`
+		theirs := `id,name,role
+1,tom,manager
+2,ryan,qa
+4,ana,ops
+5,new,qa
+`
+		merged, conflicts, err := merge(base, ours, theirs)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `id,name,role
+1,tom,<<<<<<< lead ======= manager >>>>>>>
+2,ryan,qa
+4,ana,ops
+5,new,<<<<<<< dev ======= qa >>>>>>>
+`, merged)
+		assert.Equal(t, []digest.Conflict{
+			{
+				Key:     []string{"1"},
+				Base:    []string{"1", "tom", "developer"},
+				Ours:    []string{"1", "tom", "lead"},
+				Theirs:  []string{"1", "tom", "manager"},
+				Columns: []int{2},
+				Row:     2,
+			},
+			{
+				Key:     []string{"5"},
+				Ours:    []string{"5", "new", "dev"},
+				Theirs:  []string{"5", "new", "qa"},
+				Columns: []int{2},
+				Row:     5,
+			},
+		}, conflicts)
+	})
+
+	t.Run("should keep rows modified on one side and deleted on the other", func(t *testing.T) {
+		ours := `id,name,role
+1,tom,lead
+2,ryan,qa
+4,ana,ops
+`
+		theirs := `id,name,role
+2,ryan,qa
+3,emin,director
+4,ana,ops
+`
+		merged, conflicts, err := merge(base, ours, theirs)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `id,name,role
+1,tom,lead
+2,ryan,qa
+4,ana,ops
+3,emin,director
+`, merged)
+		assert.Equal(t, []digest.Conflict{
+			{Key: []string{"1"}, Base: []string{"1", "tom", "developer"}, Ours: []string{"1", "tom", "lead"}, Row: 2},
+			{Key: []string{"3"}, Base: []string{"3", "emin", "pm"}, Theirs: []string{"3", "emin", "director"}, Row: 5},
+		}, conflicts)
+	})
+
+	t.Run("should not merge repeated keys", func(t *testing.T) {
+		_, _, err := merge(base, base+"1,tom,again\n", base)
+
+		assert.EqualError(t, err, `duplicate primary key "1" on lines 2, 6 of ours file. Merging needs unique keys`)
+	})
+}
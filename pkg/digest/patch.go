@@ -0,0 +1,266 @@
+package digest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cespare/xxhash"
+)
+
+const (
+	// patchFormat tells a patch apart from other JSON files
+	patchFormat  = "csvdiff-patch"
+	patchVersion = 1
+)
+
+// PatchOp is what a change of a patch does to its row
+type PatchOp string
+
+const (
+	// PatchAdd adds Row
+	PatchAdd PatchOp = "add"
+	// PatchDelete deletes Row
+	PatchDelete PatchOp = "delete"
+	// PatchUpdate changes the Cells of the row from Old to New
+	PatchUpdate PatchOp = "update"
+)
+
+// Patch holds the changes that turn base into delta row by row.
+// Rows are found by their values at Key.
+type Patch struct {
+	Key     Positions
+	Changes []RowChange
+}
+
+// RowChange is a change to the row with Key.
+// Row is the added or deleted row and Cells the cells of an updated row.
+type RowChange struct {
+	Op    PatchOp
+	Key   []string
+	Row   []string     `json:",omitempty"`
+	Cells []CellChange `json:",omitempty"`
+}
+
+// CellChange is a cell of an updated row at Column changing from Old to New
+type CellChange struct {
+	Column int
+	Old    string
+	New    string
+}
+
+// NewPatch creates the patch of diff with the rows keyed by key.
+//
+// Additions, deletions, modifications and rekeyings are in the patch.
+// Moves are not, as applying a patch keeps the order of base.
+// Every cell of a modified row that changed is updated, compared or not.
+// A patch needs unique keys and the full rows, so diff may not have duplicates.
+func NewPatch(diff Differences, key Positions) (Patch, error) {
+	if len(diff.Duplicates) > 0 {
+		return Patch{}, fmt.Errorf("a patch needs unique primary keys but %q repeats", strings.Join(diff.Duplicates[0].Key, ","))
+	}
+
+	patch := Patch{Key: key, Changes: make([]RowChange, 0, len(diff.Additions)+len(diff.Modifications)+len(diff.Deletions))}
+	add := func(row []string) {
+		patch.Changes = append(patch.Changes, RowChange{Op: PatchAdd, Key: key.pluck(row), Row: row})
+	}
+	remove := func(row []string) {
+		patch.Changes = append(patch.Changes, RowChange{Op: PatchDelete, Key: key.pluck(row), Row: row})
+	}
+
+	for _, deletion := range diff.Deletions {
+		remove(deletion.Row)
+	}
+	for _, rekeying := range diff.Rekeyings {
+		remove(rekeying.Original)
+	}
+	for _, modification := range diff.Modifications {
+		var cells []CellChange
+		columns := len(modification.Original)
+		if len(modification.Current) > columns {
+			columns = len(modification.Current)
+		}
+		for i := 0; i < columns; i++ {
+			old, current := cell(modification.Original, i), cell(modification.Current, i)
+			if old != current {
+				cells = append(cells, CellChange{Column: i, Old: old, New: current})
+			}
+		}
+		patch.Changes = append(patch.Changes, RowChange{Op: PatchUpdate, Key: key.pluck(modification.Current), Cells: cells})
+	}
+	for _, rekeying := range diff.Rekeyings {
+		add(rekeying.Current)
+	}
+	for _, addition := range diff.Additions {
+		add(addition.Row)
+	}
+
+	return patch, nil
+}
+
+// Reverse returns the patch that undoes p.
+// Additions become deletions and updates change New back to Old.
+func (p Patch) Reverse() Patch {
+	reversed := Patch{Key: p.Key, Changes: make([]RowChange, 0, len(p.Changes))}
+	for i := len(p.Changes) - 1; i >= 0; i-- {
+		change := p.Changes[i]
+		switch change.Op {
+		case PatchAdd:
+			change.Op = PatchDelete
+		case PatchDelete:
+			change.Op = PatchAdd
+		case PatchUpdate:
+			cells := make([]CellChange, 0, len(change.Cells))
+			for _, c := range change.Cells {
+				cells = append(cells, CellChange{Column: c.Column, Old: c.New, New: c.Old})
+			}
+			change.Cells = cells
+		}
+		reversed.Changes = append(reversed.Changes, change)
+	}
+	return reversed
+}
+
+// patchFile is how a patch is written
+type patchFile struct {
+	Format  string
+	Version int
+	Patch
+}
+
+// WritePatch writes p to w as JSON
+func WritePatch(w io.Writer, p Patch) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(patchFile{Format: patchFormat, Version: patchVersion, Patch: p}); err != nil {
+		return fmt.Errorf("error writing patch: %v", err)
+	}
+	return nil
+}
+
+// ReadPatch reads a patch written by WritePatch
+func ReadPatch(r io.Reader) (Patch, error) {
+	var file patchFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil || file.Format != patchFormat {
+		return Patch{}, fmt.Errorf("not a csvdiff patch")
+	}
+	if file.Version != patchVersion {
+		return Patch{}, fmt.Errorf("unsupported patch version %d", file.Version)
+	}
+
+	for _, change := range file.Changes {
+		switch change.Op {
+		case PatchAdd, PatchDelete, PatchUpdate:
+		default:
+			return Patch{}, fmt.Errorf("unknown patch op %q for key %q", change.Op, strings.Join(change.Key, ","))
+		}
+	}
+
+	return file.Patch, nil
+}
+
+// PatchConflictError is returned by Apply when the file drifted
+// from the base the patch was made from at the row with Key
+type PatchConflictError struct {
+	Key    []string
+	Reason string
+}
+
+func (e *PatchConflictError) Error() string {
+	return fmt.Sprintf("patch does not apply: %s", e.Reason)
+}
+
+// Apply applies patch to the file of config and writes the patched rows to w
+// in the order of the file, followed by the added rows. All rows are held in memory.
+//
+// The rows are keyed by the Key of patch and written with the Separator of config.
+// Applying fails if the file drifted from the base the patch was made from:
+// a deleted row must be as it was, an updated row must have the Old cells
+// and an added row must be missing. Otherwise it returns a *PatchConflictError.
+func Apply(config Config, patch Patch, w io.Writer) error {
+	config.Key = patch.Key
+	rows, err := uniqueRows(config, "base", "Applying a patch")
+	if err != nil {
+		return err
+	}
+
+	separator := string(config.Separator)
+	changes := make(map[uint64]RowChange, len(patch.Changes))
+	for _, change := range patch.Changes {
+		k := xxhash.Sum64String(Positions{}.encode(change.Key, separator))
+		if _, present := changes[k]; present {
+			return fmt.Errorf("patch changes key %q more than once", strings.Join(change.Key, ","))
+		}
+		changes[k] = change
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = config.Separator
+	applied := make(map[uint64]bool, len(changes))
+	for _, row := range rows {
+		change, present := changes[row.Key]
+		if !present {
+			_ = writer.Write(row.Source)
+			continue
+		}
+
+		applied[row.Key] = true
+		patched, err := change.apply(row)
+		if err != nil {
+			return &PatchConflictError{Key: change.Key, Reason: err.Error()}
+		}
+		if patched != nil {
+			_ = writer.Write(patched)
+		}
+	}
+
+	for _, change := range patch.Changes {
+		k := xxhash.Sum64String(Positions{}.encode(change.Key, separator))
+		if applied[k] {
+			continue
+		}
+		if change.Op != PatchAdd {
+			return &PatchConflictError{Key: change.Key, Reason: fmt.Sprintf("row %q to %s is missing", strings.Join(change.Key, ","), change.Op)}
+		}
+		_ = writer.Write(change.Row)
+	}
+
+	// errors are sticky and surface on Flush
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error writing patched rows: %v", err)
+	}
+
+	return nil
+}
+
+// apply applies c to row. The row is nil if it is deleted.
+func (c RowChange) apply(row Digest) ([]string, error) {
+	key := strings.Join(c.Key, ",")
+
+	switch c.Op {
+	case PatchAdd:
+		return nil, fmt.Errorf("row %q to add already exists on line %d", key, row.Line)
+	case PatchDelete:
+		if !(Positions{}).equal(c.Row, row.Source) {
+			return nil, fmt.Errorf("row %q to delete on line %d changed", key, row.Line)
+		}
+		return nil, nil
+	}
+
+	patched := make([]string, len(row.Source))
+	copy(patched, row.Source)
+	for _, cell := range c.Cells {
+		for cell.Column >= len(patched) {
+			patched = append(patched, "")
+		}
+		if patched[cell.Column] != cell.Old {
+			return nil, fmt.Errorf("row %q on line %d has %q in column %d instead of %q",
+				key, row.Line, patched[cell.Column], cell.Column, cell.Old)
+		}
+		patched[cell.Column] = cell.New
+	}
+	return patched, nil
+}
@@ -0,0 +1,111 @@
+package digest_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatch(t *testing.T) {
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ','}
+	}
+	apply := func(csv string, patch digest.Patch) (string, error) {
+		var patched bytes.Buffer
+		err := digest.Apply(config(csv), patch, &patched)
+		return patched.String(), err
+	}
+
+	base := `id,name,role
+1,tom,developer
+2,ryan,qa
+3,emin,pm
+`
+	delta := `id,name,role
+1,tom,lead
+3,emin,pm
+4,ana,"ops, infra"
+`
+	diff, err := digest.Diff(config(base), config(delta))
+	assert.NoError(t, err)
+	patch, err := digest.NewPatch(diff, []int{0})
+	assert.NoError(t, err)
+
+	t.Run("should hold the changes with the old values", func(t *testing.T) {
+		assert.Equal(t, digest.Patch{
+			Key: []int{0},
+			Changes: []digest.RowChange{
+				{Op: digest.PatchDelete, Key: []string{"2"}, Row: []string{"2", "ryan", "qa"}},
+				{Op: digest.PatchUpdate, Key: []string{"1"}, Cells: []digest.CellChange{{Column: 2, Old: "developer", New: "lead"}}},
+				{Op: digest.PatchAdd, Key: []string{"4"}, Row: []string{"4", "ana", "ops, infra"}},
+			},
+		}, patch)
+	})
+
+	t.Run("should turn base into delta", func(t *testing.T) {
+		patched, err := apply(base, patch)
+
+		assert.NoError(t, err)
+		assert.Equal(t, delta, patched)
+	})
+
+	t.Run("should turn delta back into base when reversed", func(t *testing.T) {
+		patched, err := apply(delta, patch.Reverse())
+
+		assert.NoError(t, err)
+		assert.Equal(t, `id,name,role
+1,tom,developer
+3,emin,pm
+2,ryan,qa
+`, patched)
+	})
+
+	t.Run("should read the patch it wrote", func(t *testing.T) {
+		var buffer bytes.Buffer
+		assert.NoError(t, digest.WritePatch(&buffer, patch))
+
+		read, err := digest.ReadPatch(&buffer)
+
+		assert.NoError(t, err)
+		assert.Equal(t, patch, read)
+	})
+
+	t.Run("should not read other files", func(t *testing.T) {
+		_, err := digest.ReadPatch(strings.NewReader(`{"Additions": []}`))
+
+		assert.EqualError(t, err, "not a csvdiff patch")
+	})
+
+	t.Run("should fail on drift", func(t *testing.T) {
+		drifts := []struct {
+			name string
+			csv  string
+			err  string
+		}{
+			{"of an updated row", strings.Replace(base, "tom,developer", "tom,manager", 1),
+				`patch does not apply: row "1" on line 2 has "manager" in column 2 instead of "developer"`},
+			{"of a deleted row", strings.Replace(base, "ryan,qa", "ryan,qa-lead", 1),
+				`patch does not apply: row "2" to delete on line 3 changed`},
+			{"of an added row", base + "4,ana,ops\n",
+				`patch does not apply: row "4" to add already exists on line 5`},
+			{"of a missing row", strings.Replace(base, "1,tom,developer\n", "", 1),
+				`patch does not apply: row "1" to update is missing`},
+		}
+		for _, drift := range drifts {
+			t.Run(drift.name, func(t *testing.T) {
+				_, err := apply(drift.csv, patch)
+
+				assert.EqualError(t, err, drift.err)
+			})
+		}
+	})
+
+	t.Run("should need unique keys", func(t *testing.T) {
+		_, err := digest.NewPatch(digest.Differences{Duplicates: []digest.Duplicate{{Key: []string{"1"}, Lines: []int{2, 3}}}}, []int{0})
+
+		assert.EqualError(t, err, `a patch needs unique primary keys but "1" repeats`)
+	})
+}
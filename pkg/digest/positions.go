@@ -25,6 +25,35 @@ func (p Positions) Join(csv []string, separator string) string {
 	return csvStr.String()
 }
 
+// encode plucks the values from CSV like Join, escaping the separator
+// and the escape character inside the values so that different values
+// can never encode to the same string. Values without them are kept as is.
+func (p Positions) encode(csv []string, separator string) string {
+	escape := `\`
+	if separator == escape {
+		escape = "/"
+	}
+
+	// Escaping is rare. If the joined values hold no more separators
+	// than were put between them and no escape, they need none.
+	joined := p.Join(csv, separator)
+	count := len(p)
+	if count == 0 {
+		count = len(csv)
+	}
+	if strings.Count(joined, separator) == count-1 && !strings.Contains(joined, escape) {
+		return joined
+	}
+
+	escaper := strings.NewReplacer(escape, escape+escape, separator, escape+separator)
+	values := p.pluck(csv)
+	escaped := make([]string, 0, len(values))
+	for _, value := range values {
+		escaped = append(escaped, escaper.Replace(value))
+	}
+	return strings.Join(escaped, separator)
+}
+
 // String method converts to csv mapping to positions
 // escapes necessary characters
 func (p Positions) String(csv []string, separator rune) string {
@@ -45,6 +74,56 @@ func (p Positions) String(csv []string, separator rune) string {
 	return csvWithNewLine[:len(csvWithNewLine)-1]
 }
 
+// pluck returns the values of CSV at the positions.
+// It returns all the values if positions is empty.
+func (p Positions) pluck(csv []string) []string {
+	if len(p) == 0 {
+		return csv
+	}
+
+	values := make([]string, 0, len(p))
+	for _, pos := range p {
+		values = append(values, csv[pos])
+	}
+	return values
+}
+
+// equal tells if both CSVs have the same values at the positions.
+// All the values are compared if positions is empty.
+func (p Positions) equal(a, b []string) bool {
+	if len(p) == 0 {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, pos := range p {
+		if a[pos] != b[pos] {
+			return false
+		}
+	}
+	return true
+}
+
+// same tells if both are the same positions in the same order
+func (p Positions) same(other Positions) bool {
+	if len(p) != len(other) {
+		return false
+	}
+	for i := range p {
+		if p[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // Append additional positions to existing positions.
 // Imp: Removes Duplicate. Does not mutate the original array
 func (p Positions) Append(additional Positions) Positions {
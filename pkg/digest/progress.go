@@ -0,0 +1,92 @@
+package digest
+
+import "sync"
+
+// Phase is the step a diff is at
+type Phase int
+
+const (
+	// ReadingBase reads base into memory or into spill files
+	ReadingBase Phase = iota
+	// ReadingDelta reads delta. Unless spilling, it is diffed against base as it is read.
+	ReadingDelta
+	// DiffingPartitions diffs the spill files a group of partitions at a time
+	DiffingPartitions
+	// Merging reads sorted base and delta in lockstep
+	Merging
+)
+
+func (p Phase) String() string {
+	switch p {
+	case ReadingDelta:
+		return "reading delta"
+	case DiffingPartitions:
+		return "diffing partitions"
+	case Merging:
+		return "merging"
+	default:
+		return "reading base"
+	}
+}
+
+// FileProgress counts the rows and bytes read from a file
+type FileProgress struct {
+	Rows  int64
+	Bytes int64
+}
+
+// Progress tells how far a diff has come
+type Progress struct {
+	Phase Phase
+	Base  FileProgress
+	Delta FileProgress
+}
+
+// progressTracker sums up the rows and bytes read from both files
+// and reports them to a hook. It is safe to use from several
+// go routines and never calls the hook concurrently.
+// A nil progressTracker reports nothing.
+type progressTracker struct {
+	lock     sync.Mutex
+	hook     func(Progress)
+	progress Progress
+}
+
+func newProgressTracker(hook func(Progress)) *progressTracker {
+	if hook == nil {
+		return nil
+	}
+	return &progressTracker{hook: hook}
+}
+
+func (t *progressTracker) phase(phase Phase) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.progress.Phase = phase
+	t.hook(t.progress)
+}
+
+// reader returns the function a reader of file reports
+// each batch of rows with, along with the bytes read so far
+func (t *progressTracker) reader(file File) func(rows int, bytes int64) {
+	if t == nil {
+		return nil
+	}
+
+	return func(rows int, bytes int64) {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		counts := &t.progress.Base
+		if file == Delta {
+			counts = &t.progress.Delta
+		}
+		counts.Rows += int64(rows)
+		counts.Bytes = bytes
+		t.hook(t.progress)
+	}
+}
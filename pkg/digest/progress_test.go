@@ -0,0 +1,73 @@
+package digest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffProgress(t *testing.T) {
+	var base, delta strings.Builder
+	for i := 0; i < 1500; i++ {
+		base.WriteString(fmt.Sprintf("%d,value-%d\n", i, i))
+		delta.WriteString(fmt.Sprintf("%d,modified-%d\n", i, i))
+	}
+
+	testCases := []struct {
+		name   string
+		config func(csv string) digest.Config
+		phases []digest.Phase
+	}{
+		{
+			name: "in memory",
+			config: func(csv string) digest.Config {
+				return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ','}
+			},
+			phases: []digest.Phase{digest.ReadingBase, digest.ReadingDelta},
+		},
+		{
+			name: "on disk",
+			config: func(csv string) digest.Config {
+				return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ',', MaxMemory: 1 << 20, SpillDir: t.TempDir()}
+			},
+			phases: []digest.Phase{digest.ReadingBase, digest.ReadingDelta, digest.DiffingPartitions},
+		},
+		{
+			name: "sorted",
+			config: func(csv string) digest.Config {
+				return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ',', Sorted: true, Collation: digest.NumericCollation}
+			},
+			phases: []digest.Phase{digest.Merging},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(fmt.Sprintf("should report the progress when diffing %s", tt.name), func(t *testing.T) {
+			var reports []digest.Progress
+			baseConfig := tt.config(base.String())
+			baseConfig.Progress = func(p digest.Progress) { reports = append(reports, p) }
+
+			_, err := digest.Diff(baseConfig, tt.config(delta.String()))
+			assert.NoError(t, err)
+
+			var phases []digest.Phase
+			for i, p := range reports {
+				if i == 0 || p.Phase != reports[i-1].Phase {
+					phases = append(phases, p.Phase)
+				}
+				if i > 0 {
+					assert.GreaterOrEqual(t, p.Base.Rows, reports[i-1].Base.Rows)
+					assert.GreaterOrEqual(t, p.Delta.Bytes, reports[i-1].Delta.Bytes)
+				}
+			}
+			assert.Equal(t, tt.phases, phases)
+
+			last := reports[len(reports)-1]
+			assert.Equal(t, digest.FileProgress{Rows: 1500, Bytes: int64(base.Len())}, last.Base)
+			assert.Equal(t, digest.FileProgress{Rows: 1500, Bytes: int64(delta.Len())}, last.Delta)
+		})
+	}
+}
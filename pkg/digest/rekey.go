@@ -0,0 +1,117 @@
+package digest
+
+import (
+	"github.com/cespare/xxhash"
+)
+
+// rekeyMatcher holds back additions and deletions to pair up
+// the rows whose values stayed the same under a different key.
+type rekeyMatcher struct {
+	config    Config
+	emit      func(message)
+	values    Positions
+	additions []Digest
+	deletions map[uint64][]Digest
+}
+
+func newRekeyMatcher(config Config, emit func(message)) *rekeyMatcher {
+	return &rekeyMatcher{
+		config:    config,
+		emit:      emit,
+		deletions: make(map[uint64][]Digest),
+	}
+}
+
+// hold emits all but additions and deletions
+func (r *rekeyMatcher) hold(msg message) {
+	switch msg._type {
+	case addition:
+		r.additions = append(r.additions, msg.current)
+	case deletion:
+		if hash, ok := r.hash(msg.current.Source); ok {
+			r.deletions[hash] = append(r.deletions[hash], msg.current)
+		} else {
+			r.emit(msg)
+		}
+	default:
+		r.emit(msg)
+	}
+}
+
+// flush pairs each addition with the deletion on the first line having
+// the same values and emits the rows held back. It does nothing if r is nil.
+func (r *rekeyMatcher) flush() {
+	if r == nil {
+		return
+	}
+
+	for _, deletions := range r.deletions {
+		sortByLine(deletions)
+	}
+	sortByLine(r.additions)
+
+	for _, added := range r.additions {
+		hash, ok := r.hash(added.Source)
+		if !ok {
+			r.emit(message{_type: addition, current: added})
+			continue
+		}
+
+		candidates := r.deletions[hash]
+		matched := -1
+		for i, deleted := range candidates {
+			if !r.config.Verify || r.values.equal(deleted.Source, added.Source) {
+				matched = i
+				break
+			}
+		}
+		if matched < 0 {
+			r.emit(message{_type: addition, current: added})
+			continue
+		}
+
+		r.emit(message{_type: rekey, original: candidates[matched], current: added})
+		r.deletions[hash] = append(candidates[:matched], candidates[matched+1:]...)
+	}
+
+	for _, deletions := range r.deletions {
+		for _, deleted := range deletions {
+			r.emit(message{_type: deletion, current: deleted})
+		}
+	}
+}
+
+// hash hashes the compared values of row other than the key.
+// It is false if there are no such values to pair rows by.
+func (r *rekeyMatcher) hash(row []string) (uint64, bool) {
+	if r.values == nil {
+		r.values = r.nonKey(len(row))
+	}
+	if len(r.values) == 0 {
+		return 0, false
+	}
+
+	return xxhash.Sum64String(r.values.encode(row, string(r.config.Separator))), true
+}
+
+// nonKey returns the compared positions that are not part of the key
+func (r *rekeyMatcher) nonKey(columns int) Positions {
+	values := r.config.Value
+	if len(values) == 0 {
+		values = make(Positions, 0, columns)
+		for i := 0; i < columns; i++ {
+			values = append(values, i)
+		}
+	}
+
+	nonKey := make(Positions, 0, len(values))
+	if len(r.config.Key) == 0 {
+		return nonKey
+	}
+	for _, pos := range values {
+		if !r.config.Key.Contains(pos) {
+			nonKey = append(nonKey, pos)
+		}
+	}
+	return nonKey
+}
@@ -0,0 +1,68 @@
+package digest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRekeys(t *testing.T) {
+	base := `1,tom,developer
+2,ryan,qa
+3,emin,pm
+`
+	delta := `1,tom,developer
+12,ryan,qa
+13,emin,manager
+14,ryan,qa
+`
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ',', Rekeys: true}
+	}
+
+	t.Run("should pair deletions with additions of the same values", func(t *testing.T) {
+		got, err := digest.Diff(config(base), config(delta))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []digest.Rekeying{{
+			OriginalKey:      []string{"2"},
+			CurrentKey:       []string{"12"},
+			Original:         []string{"2", "ryan", "qa"},
+			Current:          []string{"12", "ryan", "qa"},
+			OriginalPosition: digest.Position{Line: 2, Offset: 16},
+			CurrentPosition:  digest.Position{Line: 2, Offset: 16},
+		}}, got.Rekeyings)
+		assert.Equal(t, []digest.Addition{
+			{Row: []string{"13", "emin", "manager"}, Position: digest.Position{Line: 3, Offset: 27}},
+			{Row: []string{"14", "ryan", "qa"}, Position: digest.Position{Line: 4, Offset: 43}},
+		}, got.Additions)
+		assert.Equal(t, []digest.Deletion{
+			{Row: []string{"3", "emin", "pm"}, Position: digest.Position{Line: 3, Offset: 26}},
+		}, got.Deletions)
+	})
+
+	t.Run("should only pair by the compared columns", func(t *testing.T) {
+		baseConfig, deltaConfig := config(base), config(delta)
+		baseConfig.Value, deltaConfig.Value = []int{1}, []int{1}
+		got, err := digest.Diff(baseConfig, deltaConfig)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(got.Rekeyings))
+		assert.Equal(t, []string{"13", "emin", "manager"}, got.Rekeyings[1].Current)
+		assert.Equal(t, 1, len(got.Additions))
+		assert.Equal(t, 0, len(got.Deletions))
+	})
+
+	t.Run("should leave out rekeyings unless asked", func(t *testing.T) {
+		baseConfig, deltaConfig := config(base), config(delta)
+		baseConfig.Rekeys = false
+		got, err := digest.Diff(baseConfig, deltaConfig)
+
+		assert.NoError(t, err)
+		assert.Nil(t, got.Rekeyings)
+		assert.Equal(t, 3, len(got.Additions))
+		assert.Equal(t, 2, len(got.Deletions))
+	})
+}
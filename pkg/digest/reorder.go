@@ -0,0 +1,96 @@
+package digest
+
+import "sort"
+
+// reorderTracker finds the rows found in both files that moved.
+// The longest run of such rows keeping their order in both files
+// stays in place and all other rows found in both files moved.
+// Rows are numbered by their offset among the rows of their file.
+type reorderTracker struct {
+	emit    func(message)
+	offsets [2][]int64
+	matched []message
+}
+
+func newReorderTracker(emit func(message)) *reorderTracker {
+	return &reorderTracker{emit: emit}
+}
+
+// track records where the rows of msg are and emits msg
+func (r *reorderTracker) track(msg message) {
+	switch msg._type {
+	case addition:
+		r.offsets[Delta] = append(r.offsets[Delta], msg.current.Offset)
+	case deletion:
+		r.offsets[Base] = append(r.offsets[Base], msg.current.Offset)
+	case modification, unchanged:
+		r.offsets[Base] = append(r.offsets[Base], msg.original.Offset)
+		r.offsets[Delta] = append(r.offsets[Delta], msg.current.Offset)
+		r.matched = append(r.matched, msg)
+	}
+
+	r.emit(msg)
+}
+
+// flush emits the rows that moved. It does nothing if r is nil.
+func (r *reorderTracker) flush() {
+	if r == nil {
+		return
+	}
+
+	for _, offsets := range r.offsets {
+		sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	}
+	ordinal := func(file File, offset int64) int {
+		offsets := r.offsets[file]
+		return sort.Search(len(offsets), func(i int) bool { return offsets[i] >= offset }) + 1
+	}
+
+	sort.Slice(r.matched, func(i, j int) bool { return r.matched[i].current.Offset < r.matched[j].current.Offset })
+	order := make([]int, 0, len(r.matched))
+	for _, msg := range r.matched {
+		order = append(order, ordinal(Base, msg.original.Offset))
+	}
+
+	inPlace := longestIncreasing(order)
+	for i, msg := range r.matched {
+		if inPlace[i] {
+			continue
+		}
+		r.emit(message{
+			_type:    move,
+			original: msg.original,
+			current:  msg.current,
+			ordinals: [2]int{order[i], ordinal(Delta, msg.current.Offset)},
+		})
+	}
+}
+
+// longestIncreasing tells which numbers are part of
+// the longest strictly increasing subsequence of numbers
+func longestIncreasing(numbers []int) []bool {
+	// tails[k] is the index of the smallest number
+	// ending an increasing subsequence of length k+1
+	tails := make([]int, 0)
+	previous := make([]int, len(numbers))
+	for i, n := range numbers {
+		k := sort.Search(len(tails), func(k int) bool { return numbers[tails[k]] >= n })
+		previous[i] = -1
+		if k > 0 {
+			previous[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+
+	members := make([]bool, len(numbers))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+			members[i] = true
+		}
+	}
+	return members
+}
@@ -0,0 +1,66 @@
+package digest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffReorders(t *testing.T) {
+	base := `id,value
+1,a
+2,b
+3,c
+4,d
+5,e
+`
+	delta := `id,value
+4,d
+1,a
+9,x
+2,b
+3,c-modified
+`
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ',', Reorders: true}
+	}
+	want := []digest.Move{{
+		Row:              []string{"4", "d"},
+		OriginalIndex:    5,
+		CurrentIndex:     2,
+		OriginalPosition: digest.Position{Line: 5, Offset: 21},
+		CurrentPosition:  digest.Position{Line: 2, Offset: 9},
+	}}
+
+	modes := []struct {
+		name   string
+		config func(digest.Config) digest.Config
+	}{
+		{"in memory", func(c digest.Config) digest.Config { return c }},
+		{"with an index", func(c digest.Config) digest.Config { c.Index = true; return c }},
+		{"as a multiset", func(c digest.Config) digest.Config { c.Duplicates = digest.DuplicateMultiset; return c }},
+		{"on disk", func(c digest.Config) digest.Config { c.MaxMemory = 1; return c }},
+	}
+	for _, mode := range modes {
+		t.Run("should report the rows out of place "+mode.name, func(t *testing.T) {
+			got, err := digest.Diff(mode.config(config(base)), config(delta))
+
+			assert.NoError(t, err)
+			assert.Equal(t, want, got.Moves)
+			assert.Equal(t, 1, len(got.Additions))
+			assert.Equal(t, 1, len(got.Modifications))
+			assert.Equal(t, 1, len(got.Deletions))
+		})
+	}
+
+	t.Run("should leave out moves unless asked", func(t *testing.T) {
+		baseConfig := config(base)
+		baseConfig.Reorders = false
+		got, err := digest.Diff(baseConfig, config(delta))
+
+		assert.NoError(t, err)
+		assert.Nil(t, got.Moves)
+	})
+}
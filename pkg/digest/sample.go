@@ -0,0 +1,40 @@
+package digest
+
+import "math"
+
+// z95 is the z-score of a two-sided 95% confidence interval
+const z95 = 1.959964
+
+// Estimate is a count over all rows estimated from a sample,
+// with Low and High bounding its 95% confidence interval
+type Estimate struct {
+	Count float64
+	Low   float64
+	High  float64
+}
+
+// EstimateCount estimates the count over all rows from the count
+// of rows found in a sample of fraction of the key hash space.
+//
+// Each key is in the sample with a chance of fraction independent
+// of the others, so the sampled count is binomial. The interval is
+// the normal approximation, bounded below by the sampled count.
+// When nothing is found, the upper bound is the largest count for
+// which finding nothing still has a chance of 5%.
+func EstimateCount(sampled int, fraction float64) Estimate {
+	count := float64(sampled)
+	if fraction <= 0 || fraction >= 1 {
+		return Estimate{Count: count, Low: count, High: count}
+	}
+	if sampled == 0 {
+		return Estimate{High: math.Floor(math.Log(0.05) / math.Log1p(-fraction))}
+	}
+
+	estimate := count / fraction
+	margin := z95 * math.Sqrt(count*(1-fraction)) / fraction
+	return Estimate{
+		Count: estimate,
+		Low:   math.Max(count, estimate-margin),
+		High:  estimate + margin,
+	}
+}
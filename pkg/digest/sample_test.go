@@ -0,0 +1,100 @@
+package digest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSample(t *testing.T) {
+	var base, delta strings.Builder
+	for i := 1; i <= 5000; i++ {
+		switch {
+		case i%10 == 0:
+			fmt.Fprintf(&base, "%d,value-%d\n", i, i)
+			fmt.Fprintf(&delta, "%d,modified-%d\n", i, i)
+		case i%15 == 0:
+			fmt.Fprintf(&base, "%d,value-%d\n", i, i)
+		case i%20 == 1:
+			fmt.Fprintf(&delta, "%d,value-%d\n", i, i)
+		default:
+			fmt.Fprintf(&base, "%d,value-%d\n", i, i)
+			fmt.Fprintf(&delta, "%d,value-%d\n", i, i)
+		}
+	}
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ','}
+	}
+
+	full, err := digest.Diff(config(base.String()), config(delta.String()))
+	assert.NoError(t, err)
+
+	modes := []struct {
+		name   string
+		config func(digest.Config) digest.Config
+	}{
+		{"in memory", func(c digest.Config) digest.Config { return c }},
+		{"with an index", func(c digest.Config) digest.Config { c.Index = true; return c }},
+		{"on disk", func(c digest.Config) digest.Config { c.MaxMemory = 1; return c }},
+		{"sorted", func(c digest.Config) digest.Config { c.Sorted, c.Collation = true, digest.NumericCollation; return c }},
+	}
+	var sample digest.Differences
+	for i, mode := range modes {
+		t.Run("should sample the same keys "+mode.name, func(t *testing.T) {
+			baseConfig := mode.config(config(base.String()))
+			baseConfig.Sample = 0.1
+			got, err := digest.Diff(baseConfig, config(delta.String()))
+
+			assert.NoError(t, err)
+			if i == 0 {
+				sample = got
+				return
+			}
+			assert.Equal(t, sample, got)
+		})
+	}
+
+	t.Run("should keep only changes of the full diff", func(t *testing.T) {
+		assert.NotEmpty(t, sample.Additions)
+		assert.NotEmpty(t, sample.Modifications)
+		assert.NotEmpty(t, sample.Deletions)
+		assert.Subset(t, full.Additions, sample.Additions)
+		assert.Subset(t, full.Modifications, sample.Modifications)
+		assert.Subset(t, full.Deletions, sample.Deletions)
+	})
+
+	t.Run("should estimate the counts of the full diff", func(t *testing.T) {
+		counts := [][2]int{
+			{len(sample.Additions), len(full.Additions)},
+			{len(sample.Modifications), len(full.Modifications)},
+			{len(sample.Deletions), len(full.Deletions)},
+		}
+		for _, count := range counts {
+			estimate := digest.EstimateCount(count[0], 0.1)
+
+			assert.True(t, estimate.Low <= float64(count[1]) && float64(count[1]) <= estimate.High,
+				"%d not in %+v", count[1], estimate)
+		}
+	})
+}
+
+func TestEstimateCount(t *testing.T) {
+	t.Run("should scale the sampled count", func(t *testing.T) {
+		estimate := digest.EstimateCount(100, 0.1)
+
+		assert.Equal(t, 1000.0, estimate.Count)
+		assert.InDelta(t, 1000-186, estimate.Low, 1)
+		assert.InDelta(t, 1000+186, estimate.High, 1)
+	})
+
+	t.Run("should bound a count never sampled", func(t *testing.T) {
+		assert.Equal(t, digest.Estimate{High: 28}, digest.EstimateCount(0, 0.1))
+	})
+
+	t.Run("should be exact without sampling", func(t *testing.T) {
+		assert.Equal(t, digest.Estimate{Count: 7, Low: 7, High: 7}, digest.EstimateCount(7, 0))
+	})
+}
@@ -0,0 +1,136 @@
+package digest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// SetOperation picks rows of base or delta by whether their primary key
+// is in the other file and whether their values changed
+type SetOperation int
+
+const (
+	// OnlyBase picks the rows of base whose key is not in delta
+	OnlyBase SetOperation = iota
+	// OnlyDelta picks the rows of delta whose key is not in base
+	OnlyDelta
+	// Intersect picks the rows of base whose key is in delta as well
+	Intersect
+	// Changed picks the rows of delta whose key is in base with other values
+	Changed
+)
+
+// Select diffs baseConfig and deltaConfig like Diff and writes the rows
+// op picks to w as they are in their file and in the order of that file.
+// Rows are copied byte for byte, so their quoting, separator and line
+// endings are kept. Primary keys must be unique in both files.
+//
+// If the config of the file whose rows are picked skips a header, the
+// header is copied first. Both files are read again for their rows and
+// need an io.ReadSeeker, as Config.Index does.
+func Select(baseConfig, deltaConfig Config, op SetOperation, w io.Writer) error {
+	base, seekable := baseConfig.Reader.(io.ReadSeeker)
+	delta, deltaSeekable := deltaConfig.Reader.(io.ReadSeeker)
+	if !seekable || !deltaSeekable {
+		return fmt.Errorf("selecting reads the files again and needs an io.ReadSeeker")
+	}
+	baseStart, err := base.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("error processing base file: %v", err)
+	}
+	deltaStart, err := delta.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("error processing delta file: %v", err)
+	}
+
+	baseConfig.Duplicates, deltaConfig.Duplicates = DuplicateFail, DuplicateFail
+	baseConfig.Order = FileOrder
+	diff, err := Diff(baseConfig, deltaConfig)
+	if err != nil {
+		return err
+	}
+
+	// rows are picked by their offset, and Intersect
+	// picks the rows of base that were not deleted
+	picked := make(map[int64]bool)
+	switch op {
+	case OnlyBase, Intersect:
+		for _, deletion := range diff.Deletions {
+			picked[deletion.Position.Offset] = true
+		}
+	case OnlyDelta:
+		for _, addition := range diff.Additions {
+			picked[addition.Position.Offset] = true
+		}
+	case Changed:
+		for _, modification := range diff.Modifications {
+			picked[modification.CurrentPosition.Offset] = true
+		}
+	}
+
+	if op == OnlyDelta || op == Changed {
+		err = copyRows(w, delta, deltaStart, deltaConfig, picked, true)
+		if err != nil {
+			return fmt.Errorf("error processing delta file: %v", err)
+		}
+		return nil
+	}
+	if err = copyRows(w, base, baseStart, baseConfig, picked, op == OnlyBase); err != nil {
+		return fmt.Errorf("error processing base file: %v", err)
+	}
+	return nil
+}
+
+// copyRows reads file again from start and copies the header, if
+// config skips one, and the rows whose offset is in picked as keep
+// tells to w as they are
+func copyRows(w io.Writer, file io.ReadSeeker, start int64, config Config, picked map[int64]bool, keep bool) error {
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	raw := &rawReader{reader: file}
+	reader := csv.NewReader(raw)
+	reader.Comma = config.Separator
+	reader.LazyQuotes = config.LazyQuotes
+	for header := config.SkipHeader; ; header = false {
+		offset := reader.InputOffset()
+		_, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		row := raw.next(offset, reader.InputOffset())
+		if header || picked[offset] == keep {
+			if _, err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// rawReader keeps the bytes read from reader that
+// are not taken yet, to copy csv rows as they are
+type rawReader struct {
+	reader io.Reader
+	// offset is the offset of the first byte kept
+	offset int64
+	bytes  []byte
+}
+
+func (r *rawReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.bytes = append(r.bytes, p[:n]...)
+	return n, err
+}
+
+// next takes the bytes from start to end
+func (r *rawReader) next(start, end int64) []byte {
+	row := r.bytes[start-r.offset : end-r.offset]
+	r.bytes, r.offset = r.bytes[end-r.offset:], end
+	return row
+}
@@ -0,0 +1,89 @@
+package digest_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelect(t *testing.T) {
+	base := `id,name,age
+1,tom,2
+2,"ryan, jr",20
+3,emin,40
+5,ana,30
+`
+	delta := `id,name,age
+5,ana,30
+2,"ryan, jr",23
+1,tom,2
+4,joe,10
+`
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Value: []int{1, 2}, Separator: ',', SkipHeader: true}
+	}
+
+	testCases := []struct {
+		name     string
+		op       digest.SetOperation
+		expected string
+	}{
+		{name: "rows only in base", op: digest.OnlyBase, expected: "id,name,age\n3,emin,40\n"},
+		{name: "rows only in delta", op: digest.OnlyDelta, expected: "id,name,age\n4,joe,10\n"},
+		{name: "rows of base in both", op: digest.Intersect, expected: "id,name,age\n1,tom,2\n2,\"ryan, jr\",20\n5,ana,30\n"},
+		{name: "changed rows of delta", op: digest.Changed, expected: "id,name,age\n2,\"ryan, jr\",23\n"},
+	}
+	for _, tt := range testCases {
+		t.Run("should write the "+tt.name, func(t *testing.T) {
+			var selected bytes.Buffer
+			err := digest.Select(config(base), config(delta), tt.op, &selected)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, selected.String())
+		})
+	}
+
+	t.Run("should keep the first lines without a header", func(t *testing.T) {
+		baseConfig, deltaConfig := config(base), config(delta)
+		baseConfig.SkipHeader, deltaConfig.SkipHeader = false, false
+		var selected bytes.Buffer
+		err := digest.Select(baseConfig, deltaConfig, digest.Intersect, &selected)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "id,name,age\n1,tom,2\n2,\"ryan, jr\",20\n5,ana,30\n", selected.String())
+	})
+
+	t.Run("should fail on duplicate keys", func(t *testing.T) {
+		err := digest.Select(config(base), config(delta+"4,joe,11\n"), digest.OnlyDelta, &bytes.Buffer{})
+
+		assert.IsType(t, &digest.DuplicateKeyError{}, err)
+	})
+
+	t.Run("should copy the rows as they are", func(t *testing.T) {
+		base := "id;name\r\n\"1\";tom\r\n2;ryan \"jr\"\r\n"
+		delta := "id;name\r\n1;tom\r\n"
+		config := func(csv string) digest.Config {
+			return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ';', LazyQuotes: true, SkipHeader: true}
+		}
+
+		var selected bytes.Buffer
+		assert.NoError(t, digest.Select(config(base), config(delta), digest.Intersect, &selected))
+		assert.Equal(t, "id;name\r\n\"1\";tom\r\n", selected.String())
+
+		selected.Reset()
+		assert.NoError(t, digest.Select(config(base), config(delta), digest.OnlyBase, &selected))
+		assert.Equal(t, "id;name\r\n2;ryan \"jr\"\r\n", selected.String())
+	})
+
+	t.Run("should need to read the files again", func(t *testing.T) {
+		baseConfig := config(base)
+		baseConfig.Reader = io.MultiReader(strings.NewReader(base))
+		err := digest.Select(baseConfig, config(delta), digest.Intersect, &bytes.Buffer{})
+
+		assert.EqualError(t, err, "selecting reads the files again and needs an io.ReadSeeker")
+	})
+}
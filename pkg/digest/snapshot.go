@@ -0,0 +1,337 @@
+package digest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+)
+
+const (
+	// snapshotMagic starts every snapshot once it is decompressed
+	snapshotMagic   = "csvdiff-snapshot"
+	snapshotVersion = 2
+)
+
+// ErrNotSnapshot is returned when reading a snapshot from a file that is not one
+var ErrNotSnapshot = errors.New("not a csvdiff snapshot")
+
+// Snapshot describes how the digests in a snapshot were created.
+// A snapshot can only be diffed against a file digested the same way.
+//
+// Columns: The number of columns in each row of the file.
+// Rows: The rows are stored. Only their key columns are stored otherwise.
+// Header: The first line of the file names the columns and is not stored.
+type Snapshot struct {
+	Key        Positions
+	Value      Positions
+	Separator  rune
+	LazyQuotes bool
+	Columns    int
+	Rows       bool
+	Header     bool
+}
+
+// WriteSnapshot digests the file of config and writes the digest
+// of every row to w, compressed. The snapshot can be diffed as base
+// in place of the file by setting Config.Snapshot.
+//
+// If rows is set, the rows are stored so that modifications and deletions
+// show the original row. Otherwise only the key columns are kept and the
+// other columns are empty.
+func WriteSnapshot(w io.Writer, config Config, rows bool) error {
+	compressed := gzip.NewWriter(w)
+	writer := bufio.NewWriter(compressed)
+	buffer := make([]byte, binary.MaxVarintLen64)
+
+	snapshot := Snapshot{
+		Key:        config.Key,
+		Value:      config.Value,
+		Separator:  config.Separator,
+		LazyQuotes: config.LazyQuotes,
+		Rows:       rows,
+		Header:     config.SkipHeader,
+	}
+
+	// The header holds the number of columns, which is
+	// only known once the first row has been digested.
+	headerWritten := false
+	writeHeader := func(columns int) {
+		snapshot.Columns = columns
+		snapshot.write(writer, buffer)
+		headerWritten = true
+	}
+
+	digestChannel, errorChannel := NewEngine(config).StreamDigests()
+
+	var writeErr error
+	for digests := range digestChannel {
+		for _, d := range digests {
+			if writeErr != nil {
+				continue
+			}
+			if !headerWritten {
+				writeHeader(len(d.Source))
+			}
+			if !rows {
+				d.Source = keyOnly(d.Source, config.Key)
+			}
+			writeErr = writeDigest(writer, buffer, d)
+		}
+	}
+
+	if err := <-errorChannel; err != nil {
+		return err
+	}
+	if !headerWritten {
+		writeHeader(0)
+	}
+	if writeErr == nil {
+		writeErr = writer.Flush()
+	}
+	if writeErr == nil {
+		writeErr = compressed.Close()
+	}
+	if writeErr != nil {
+		return fmt.Errorf("error writing snapshot: %v", writeErr)
+	}
+
+	return nil
+}
+
+// ReadSnapshot reads how the snapshot in r was taken.
+// It returns ErrNotSnapshot if r does not hold a snapshot.
+func ReadSnapshot(r io.Reader) (Snapshot, error) {
+	snapshot, _, err := openSnapshot(r)
+	return snapshot, err
+}
+
+// openSnapshot reads the header of the snapshot in r and
+// returns a reader positioned at the first digest.
+func openSnapshot(r io.Reader) (Snapshot, *bufio.Reader, error) {
+	compressed, err := gzip.NewReader(r)
+	if err == gzip.ErrHeader || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return Snapshot{}, nil, ErrNotSnapshot
+	}
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+	reader := bufio.NewReader(compressed)
+
+	magic, err := readString(reader)
+	if err != nil || magic != snapshotMagic {
+		return Snapshot{}, nil, ErrNotSnapshot
+	}
+
+	version, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return Snapshot{}, nil, unexpected(err)
+	}
+	if version != snapshotVersion {
+		return Snapshot{}, nil, fmt.Errorf("snapshot version %d is not supported", version)
+	}
+
+	hash, err := readString(reader)
+	if err != nil {
+		return Snapshot{}, nil, unexpected(err)
+	}
+	if hash != HashAlgorithm {
+		return Snapshot{}, nil, fmt.Errorf("snapshot is hashed with %s, not %s", hash, HashAlgorithm)
+	}
+
+	var snapshot Snapshot
+	var fields [5]uint64
+	if snapshot.Key, err = readPositions(reader); err != nil {
+		return Snapshot{}, nil, unexpected(err)
+	}
+	if snapshot.Value, err = readPositions(reader); err != nil {
+		return Snapshot{}, nil, unexpected(err)
+	}
+	for i := range fields {
+		if fields[i], err = binary.ReadUvarint(reader); err != nil {
+			return Snapshot{}, nil, unexpected(err)
+		}
+	}
+	snapshot.Separator = rune(fields[0])
+	snapshot.LazyQuotes = fields[1] == 1
+	snapshot.Columns = int(fields[2])
+	snapshot.Rows = fields[3] == 1
+	snapshot.Header = fields[4] == 1
+
+	return snapshot, reader, nil
+}
+
+func (s Snapshot) write(w *bufio.Writer, buffer []byte) {
+	writeString(w, buffer, snapshotMagic)
+	writeUvarint(w, buffer, snapshotVersion)
+	writeString(w, buffer, HashAlgorithm)
+	writePositions(w, buffer, s.Key)
+	writePositions(w, buffer, s.Value)
+	writeUvarint(w, buffer, uint64(s.Separator))
+	writeUvarint(w, buffer, boolean(s.LazyQuotes))
+	writeUvarint(w, buffer, uint64(s.Columns))
+	writeUvarint(w, buffer, boolean(s.Rows))
+	writeUvarint(w, buffer, boolean(s.Header))
+}
+
+// check tells if the snapshot can be diffed against the file of config
+func (s Snapshot) check(config Config) error {
+	if !s.Key.same(config.Key) {
+		return fmt.Errorf("snapshot has primary key %s, not %s", columns(s.Key), columns(config.Key))
+	}
+	if !s.Value.same(config.Value) {
+		return fmt.Errorf("snapshot compares %s, not %s", columns(s.Value), columns(config.Value))
+	}
+	if s.Separator != config.Separator {
+		return fmt.Errorf("snapshot has separator %q, not %q", s.Separator, config.Separator)
+	}
+	if s.LazyQuotes != config.LazyQuotes {
+		return fmt.Errorf("snapshot has lazy quotes %t, not %t", s.LazyQuotes, config.LazyQuotes)
+	}
+	if s.Header != config.SkipHeader {
+		return fmt.Errorf("snapshot has header %t, not %t", s.Header, config.SkipHeader)
+	}
+
+	return nil
+}
+
+// streamSnapshot reads back the digests of the snapshot of baseConfig.
+// It returns channels like Engine.StreamDigestsContext. keyOnly is set
+// before any digest is sent if the snapshot holds only the key columns.
+func streamSnapshot(ctx context.Context, baseConfig, deltaConfig Config, onRead func(rows int, bytes int64), keyOnly *bool) (chan []Digest, chan error) {
+	maxProcs := runtime.NumCPU()
+	digestChannel := make(chan []Digest, bufferSize*maxProcs)
+	errorChannel := make(chan error, 1)
+
+	go func() {
+		defer close(errorChannel)
+		defer close(digestChannel)
+
+		errorChannel <- func() error {
+			counter := &countingReader{reader: baseConfig.Reader}
+			snapshot, reader, err := openSnapshot(counter)
+			if err != nil {
+				return err
+			}
+			if err := snapshot.check(deltaConfig); err != nil {
+				return err
+			}
+			if baseConfig.Verify && !snapshot.Rows {
+				return fmt.Errorf("verifying needs a snapshot with rows")
+			}
+			*keyOnly = !snapshot.Rows
+
+			for {
+				digests, err := readDigests(reader)
+				if len(digests) > 0 && onRead != nil {
+					onRead(len(digests), counter.bytes)
+				}
+				if digests = baseConfig.sample(digests); len(digests) > 0 {
+					select {
+					case digestChannel <- digests:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}()
+	}()
+
+	return digestChannel, errorChannel
+}
+
+// countingReader counts the bytes read from reader
+type countingReader struct {
+	reader io.Reader
+	bytes  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.bytes += int64(n)
+	return n, err
+}
+
+// columns describes positions like "columns [1 2]"
+func columns(p Positions) string {
+	if len(p) == 0 {
+		return "all columns"
+	}
+	return fmt.Sprintf("columns %v", []int(p))
+}
+
+// keyOnly returns a copy of row with all but the key columns empty
+func keyOnly(row []string, key Positions) []string {
+	if len(key) == 0 {
+		return row
+	}
+
+	cells := make([]string, len(row))
+	for _, pos := range key {
+		cells[pos] = row[pos]
+	}
+	return cells
+}
+
+func writeString(w *bufio.Writer, buffer []byte, s string) {
+	writeUvarint(w, buffer, uint64(len(s)))
+	_, _ = w.WriteString(s)
+}
+
+func readString(r *bufio.Reader) (string, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if size > 1<<16 {
+		return "", ErrNotSnapshot
+	}
+	s := make([]byte, size)
+	if _, err := io.ReadFull(r, s); err != nil {
+		return "", unexpected(err)
+	}
+	return string(s), nil
+}
+
+func writePositions(w *bufio.Writer, buffer []byte, p Positions) {
+	writeUvarint(w, buffer, uint64(len(p)))
+	for _, pos := range p {
+		writeUvarint(w, buffer, uint64(pos))
+	}
+}
+
+func readPositions(r *bufio.Reader) (Positions, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if count > 1<<16 {
+		return nil, ErrNotSnapshot
+	}
+	p := make(Positions, 0, count)
+	for i := uint64(0); i < count; i++ {
+		pos, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, int(pos))
+	}
+	return p, nil
+}
+
+func boolean(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
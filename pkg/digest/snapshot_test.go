@@ -0,0 +1,132 @@
+package digest_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	base := `1,one,a
+2,two,b
+3,three,c
+`
+	delta := `1,one,a
+2,two-modified,b
+4,four,d
+`
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Value: []int{1}, Separator: ','}
+	}
+	snapshot := func(rows bool) *bytes.Buffer {
+		var buffer bytes.Buffer
+		assert.NoError(t, digest.WriteSnapshot(&buffer, config(base), rows))
+		return &buffer
+	}
+
+	t.Run("should describe how it was taken", func(t *testing.T) {
+		got, err := digest.ReadSnapshot(snapshot(false))
+
+		assert.NoError(t, err)
+		assert.Equal(t, digest.Snapshot{Key: []int{0}, Value: []int{1}, Separator: ',', Columns: 3}, got)
+	})
+
+	t.Run("should diff like the file it was taken from", func(t *testing.T) {
+		want, err := digest.Diff(config(base), config(delta))
+		assert.NoError(t, err)
+
+		baseConfig := config("")
+		baseConfig.Reader, baseConfig.Snapshot = snapshot(true), true
+		got, err := digest.Diff(baseConfig, config(delta))
+
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("should keep only the key columns without rows", func(t *testing.T) {
+		baseConfig := config("")
+		baseConfig.Reader, baseConfig.Snapshot = snapshot(false), true
+		got, err := digest.Diff(baseConfig, config(delta))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2", "", ""}, got.Modifications[0].Original)
+		assert.Equal(t, []string{"2", "two-modified", "b"}, got.Modifications[0].Current)
+		assert.Nil(t, got.Modifications[0].Columns)
+		assert.Equal(t, []digest.Deletion{{Row: []string{"3", "", ""}, Position: digest.Position{Line: 3, Offset: 16}}}, got.Deletions)
+		assert.Equal(t, 1, len(got.Additions))
+	})
+
+	t.Run("should not diff a file digested differently", func(t *testing.T) {
+		baseConfig := config("")
+		baseConfig.Reader, baseConfig.Snapshot = snapshot(true), true
+		deltaConfig := config(delta)
+		deltaConfig.Value = []int{1, 2}
+		_, err := digest.Diff(baseConfig, deltaConfig)
+
+		assert.EqualError(t, err, "error processing base file: snapshot compares columns [1], not columns [1 2]")
+	})
+
+	t.Run("should not diff a file quoted differently", func(t *testing.T) {
+		baseConfig := config("")
+		baseConfig.Reader, baseConfig.Snapshot = snapshot(true), true
+		deltaConfig := config(delta)
+		deltaConfig.LazyQuotes = true
+		_, err := digest.Diff(baseConfig, deltaConfig)
+
+		assert.EqualError(t, err, "error processing base file: snapshot has lazy quotes false, not true")
+	})
+
+	t.Run("should leave the header out", func(t *testing.T) {
+		withHeader := func(csv string) digest.Config {
+			c := config("id,name,letter\n" + csv)
+			c.SkipHeader = true
+			return c
+		}
+		var buffer bytes.Buffer
+		assert.NoError(t, digest.WriteSnapshot(&buffer, withHeader(base), true))
+		want, err := digest.Diff(withHeader(base), withHeader(delta))
+		assert.NoError(t, err)
+
+		baseConfig := config("")
+		baseConfig.Reader, baseConfig.Snapshot = bytes.NewReader(buffer.Bytes()), true
+		got, err := digest.Diff(baseConfig, withHeader(delta))
+
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+
+		baseConfig.Reader = bytes.NewReader(buffer.Bytes())
+		_, err = digest.Diff(baseConfig, config(delta))
+
+		assert.EqualError(t, err, "error processing base file: snapshot has header true, not false")
+	})
+
+	t.Run("should report the bytes of the snapshot read", func(t *testing.T) {
+		compressed := snapshot(true)
+		size := int64(compressed.Len())
+		var progress digest.Progress
+		baseConfig := config("")
+		baseConfig.Reader, baseConfig.Snapshot = compressed, true
+		baseConfig.Progress = func(p digest.Progress) { progress = p }
+		_, err := digest.Diff(baseConfig, config(delta))
+
+		assert.NoError(t, err)
+		assert.Equal(t, digest.FileProgress{Rows: 3, Bytes: size}, progress.Base)
+	})
+
+	t.Run("should not verify without rows", func(t *testing.T) {
+		baseConfig := config("")
+		baseConfig.Reader, baseConfig.Snapshot, baseConfig.Verify = snapshot(false), true, true
+		_, err := digest.Diff(baseConfig, config(delta))
+
+		assert.EqualError(t, err, "error processing base file: verifying needs a snapshot with rows")
+	})
+
+	t.Run("should tell a csv from a snapshot", func(t *testing.T) {
+		_, err := digest.ReadSnapshot(strings.NewReader(base))
+
+		assert.Equal(t, digest.ErrNotSnapshot, err)
+	})
+}
@@ -0,0 +1,347 @@
+package digest
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// UnsortedError is returned by Diff for sorted configs
+// when a file is not sorted by its primary key
+type UnsortedError struct {
+	File     File
+	Line     int
+	Key      []string
+	Previous []string
+}
+
+func (e *UnsortedError) Error() string {
+	return fmt.Sprintf("%s file is not sorted by primary key: %q on line %d comes after %q",
+		e.File, strings.Join(e.Key, ","), e.Line, strings.Join(e.Previous, ","))
+}
+
+// compareKeys orders keys column by column and each cell byte by byte.
+// It is a total order, so rows sorted by it come out the same on every run.
+func compareKeys(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if order := strings.Compare(a[i], b[i]); order != 0 {
+			return order
+		}
+	}
+	return len(a) - len(b)
+}
+
+// Collation tells how the keys of sorted files compare
+type Collation int
+
+const (
+	// TextCollation compares keys column by column and each cell byte by
+	// byte, like sort and most databases order text keys. 10 comes before 9.
+	TextCollation Collation = iota
+	// NumericCollation compares keys column by column and each cell as a
+	// number, like databases order numeric keys. Every key cell must be a number.
+	NumericCollation
+)
+
+// compare orders the keys a and b. Numeric keys must be checked first.
+func (c Collation) compare(a, b []string) int {
+	if c != NumericCollation {
+		return compareKeys(a, b)
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, _ := strconv.ParseFloat(a[i], 64)
+		y, _ := strconv.ParseFloat(b[i], 64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		// equal numbers written apart, like 1 and 1.0, keep the string order
+		if order := strings.Compare(a[i], b[i]); order != 0 {
+			return order
+		}
+	}
+	return len(a) - len(b)
+}
+
+// check returns an error if the collation cannot compare key
+func (c Collation) check(file File, line int, key []string) error {
+	if c != NumericCollation {
+		return nil
+	}
+	for _, cell := range key {
+		if x, err := strconv.ParseFloat(cell, 64); err != nil || math.IsNaN(x) {
+			return fmt.Errorf("%s file has key %q on line %d that is not a number. Numeric sorting needs numbers",
+				file, strings.Join(key, ","), line)
+		}
+	}
+	return nil
+}
+
+// sortedReader reads a file sorted by key one run
+// of rows sharing a key at a time
+type sortedReader struct {
+	file     File
+	config   Config
+	reader   *csv.Reader
+	peeked   []*Digest
+	previous []string
+	// unreported rows are reported to onRead
+	// every bufferSize rows and at the end
+	unreported int
+	onRead     func(rows int, bytes int64)
+	// header is read past before the first row
+	header bool
+}
+
+func newSortedReader(file File, config Config, progress *progressTracker) *sortedReader {
+	reader := csv.NewReader(config.Reader)
+	reader.Comma = config.Separator
+	reader.LazyQuotes = config.LazyQuotes
+
+	return &sortedReader{file: file, config: config, reader: reader, onRead: progress.reader(file), header: config.SkipHeader}
+}
+
+// read returns the next row or nil at the end of the file
+func (r *sortedReader) read() (*Digest, error) {
+	rows, err := r.peek(1)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	r.peeked = r.peeked[1:]
+
+	return rows[0], nil
+}
+
+// run returns the next rows sharing a key in the sample of the config
+// or nil at the end of the file. Runs out of the sample are skipped.
+func (r *sortedReader) run() ([]Digest, error) {
+	for {
+		rows, err := r.nextRun()
+		if err != nil || rows == nil || r.config.sampledRow(rows[0].Source) {
+			return rows, err
+		}
+	}
+}
+
+// nextRun returns the next rows sharing a key or nil at the end of the file.
+// It fails if the key is not greater than the key of the previous run.
+func (r *sortedReader) nextRun() ([]Digest, error) {
+	first, err := r.read()
+	if err != nil || first == nil {
+		return nil, err
+	}
+
+	key := r.config.Key.pluck(first.Source)
+	if err := r.config.Collation.check(r.file, first.Line, key); err != nil {
+		return nil, err
+	}
+	if r.previous != nil && r.config.Collation.compare(r.previous, key) >= 0 {
+		return nil, &UnsortedError{File: r.file, Line: first.Line, Key: key, Previous: r.previous}
+	}
+	r.previous = key
+
+	rows := []Digest{*first}
+	for {
+		next, err := r.read()
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			break
+		}
+		if !r.config.Key.equal(first.Source, next.Source) {
+			r.peeked = append([]*Digest{next}, r.peeked...)
+			break
+		}
+		rows = append(rows, *next)
+	}
+
+	if len(rows) > 1 && r.config.Duplicates == DuplicateFail {
+		return nil, &DuplicateKeyError{runDuplicate(r.file, r.config.Key, rows)}
+	}
+
+	return rows, nil
+}
+
+func runDuplicate(file File, key Positions, rows []Digest) Duplicate {
+	lines := make([]int, 0, len(rows))
+	for _, row := range rows {
+		lines = append(lines, row.Line)
+	}
+
+	return Duplicate{File: file, Key: key.pluck(rows[0].Source), Lines: lines}
+}
+
+// diffSorted diffs files sorted by key without holding either in memory.
+// If a file is not sorted, it returns an UnsortedError.
+func diffSorted(ctx context.Context, baseConfig, deltaConfig Config, progress *progressTracker, emit func(message)) error {
+	progress.phase(Merging)
+	deltaConfig.Collation = baseConfig.Collation
+	base := newSortedReader(Base, baseConfig, progress)
+	delta := newSortedReader(Delta, deltaConfig, progress)
+
+	return mergeSorted(ctx, base, delta, baseConfig, emit)
+}
+
+// peek reads the next n rows without consuming them
+func (r *sortedReader) peek(n int) ([]*Digest, error) {
+	if r.header {
+		r.header = false
+		if err := skipHeader(r.reader, r.config); err != nil {
+			return nil, fmt.Errorf("error processing %s file: %v", r.file, err)
+		}
+	}
+	for len(r.peeked) < n {
+		offset := r.reader.InputOffset()
+		line, err := r.reader.Read()
+		if err == io.EOF {
+			r.report()
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error processing %s file: %v", r.file, err)
+		}
+		if r.unreported++; r.unreported == bufferSize {
+			r.report()
+		}
+		lineNumber, _ := r.reader.FieldPos(0)
+		r.peeked = append(r.peeked, &Digest{Source: line, Line: lineNumber, Offset: offset})
+	}
+
+	return r.peeked, nil
+}
+
+func (r *sortedReader) report() {
+	if r.onRead != nil && r.unreported > 0 {
+		r.onRead(r.unreported, r.reader.InputOffset())
+	}
+	r.unreported = 0
+}
+
+func mergeSorted(ctx context.Context, base, delta *sortedReader, config Config, emit func(message)) error {
+	baseRun, err := base.run()
+	if err != nil {
+		return err
+	}
+	deltaRun, err := delta.run()
+	if err != nil {
+		return err
+	}
+
+	for baseRun != nil || deltaRun != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var order int
+		switch {
+		case baseRun == nil:
+			order = 1
+		case deltaRun == nil:
+			order = -1
+		default:
+			order = config.Collation.compare(config.Key.pluck(baseRun[0].Source), config.Key.pluck(deltaRun[0].Source))
+		}
+
+		switch {
+		case order < 0:
+			emitRuns(baseRun, nil, config, emit)
+			if baseRun, err = base.run(); err != nil {
+				return err
+			}
+		case order > 0:
+			emitRuns(nil, deltaRun, config, emit)
+			if deltaRun, err = delta.run(); err != nil {
+				return err
+			}
+		default:
+			emitRuns(baseRun, deltaRun, config, emit)
+			if baseRun, err = base.run(); err != nil {
+				return err
+			}
+			if deltaRun, err = delta.run(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// emitRuns diffs the base and delta rows sharing a key.
+// Like the other diffs, every row is diffed on its own unless diffing as a multiset.
+func emitRuns(original, current []Digest, config Config, emit func(message)) {
+	for file, rows := range [2][]Digest{original, current} {
+		if len(rows) > 1 {
+			emit(message{_type: duplicate, duplicate: runDuplicate(File(file), config.Key, rows)})
+		}
+	}
+
+	if config.Duplicates != DuplicateMultiset {
+		if len(original) == 0 {
+			for _, c := range current {
+				emit(message{_type: addition, current: c})
+			}
+			return
+		}
+
+		if len(current) == 0 {
+			for _, o := range original {
+				emit(message{_type: deletion, current: o})
+			}
+			return
+		}
+		for _, c := range current {
+			for _, o := range original {
+				if !config.Value.equal(o.Source, c.Source) {
+					emit(message{_type: modification, original: o, current: c})
+				} else if config.sendsUnchanged() {
+					emit(message{_type: unchanged, original: o, current: c})
+				}
+			}
+		}
+		return
+	}
+
+	// Cancel out identical rows and pair up the rest in line order
+	remaining := make([]Digest, 0, len(original))
+	unmatched := make([]Digest, 0, len(current))
+	used := make([]bool, len(original))
+	for _, c := range current {
+		matched := false
+		for i, o := range original {
+			if !used[i] && config.Value.equal(o.Source, c.Source) {
+				used[i], matched = true, true
+				if config.sendsUnchanged() {
+					emit(message{_type: unchanged, original: o, current: c})
+				}
+				break
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, c)
+		}
+	}
+	for i, o := range original {
+		if !used[i] {
+			remaining = append(remaining, o)
+		}
+	}
+
+	for i, c := range unmatched {
+		if i < len(remaining) {
+			emit(message{_type: modification, original: remaining[i], current: c})
+		} else {
+			emit(message{_type: addition, current: c})
+		}
+	}
+	for i := len(unmatched); i < len(remaining); i++ {
+		emit(message{_type: deletion, current: remaining[i]})
+	}
+}
@@ -0,0 +1,444 @@
+package digest
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// partitionCount is the number of spill files each input is split into,
+// and the most a partition is split into again. Rows with the same key
+// always land in the same partition. It is kept low enough for the files
+// of one input to be open at the same time.
+const partitionCount = 128
+
+// maxSplitLevel bounds how often a partition costlier than MaxMemory is
+// split again. partitionCount to the power of maxSplitLevel+1 partitions
+// are enough for any input whose rows do not share a key.
+const maxSplitLevel = 4
+
+// minMaxMemory is the least memory a diff on disk is bounded to. Smaller
+// bounds would split partitions into files of a few rows each.
+const minMaxMemory = 64 << 10
+
+const (
+	// baseRowOverhead approximates the bytes a base row costs in a
+	// FileDigest on top of its cells: the map entries and slice headers.
+	baseRowOverhead = 160
+	// deltaRowOverhead approximates the bytes a delta row costs
+	// while streaming, which is its entry in the duplicate tracker.
+	deltaRowOverhead = 48
+	// stringHeaderSize is the size of a string header on 64 bit platforms
+	stringHeaderSize = 16
+)
+
+// spill hash-partitions digests into files on disk.
+// Files are created for the partitions that get rows only.
+type spill struct {
+	dir      string
+	name     string
+	level    int
+	overhead int64
+	paths    []string
+	files    []*os.File
+	writers  []*bufio.Writer
+	costs    []int64
+	rows     []int
+	buffer   []byte
+}
+
+// newSpill creates a spill of count partitions whose rows cost overhead
+// each on top of their cells. Spills of the same level and count put the
+// rows of a key in the same partition.
+func newSpill(dir, name string, level, count int, overhead int64) *spill {
+	s := &spill{
+		dir:      dir,
+		name:     name,
+		level:    level,
+		overhead: overhead,
+		paths:    make([]string, count),
+		files:    make([]*os.File, count),
+		writers:  make([]*bufio.Writer, count),
+		costs:    make([]int64, count),
+		rows:     make([]int, count),
+		buffer:   make([]byte, binary.MaxVarintLen64),
+	}
+	for i := range s.paths {
+		s.paths[i] = filepath.Join(dir, fmt.Sprintf("%s-%03d", name, i))
+	}
+
+	return s
+}
+
+// partitionOf spreads keys over count partitions of a spill at level.
+// Each level mixes the key with its own seed, so the keys of a partition
+// split again spread over all partitions of the next level.
+func partitionOf(key uint64, level, count int) int {
+	// the finalizer of splitmix64
+	h := key + uint64(level)*0x9e3779b97f4a7c15
+	h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+	h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+	h ^= h >> 31
+	return int(h % uint64(count))
+}
+
+// write appends d to its partition.
+// The cost of the partition grows by the overhead plus the size of the cells.
+func (s *spill) write(d Digest) error {
+	partition := partitionOf(d.Key, s.level, len(s.paths))
+	if s.writers[partition] == nil {
+		file, err := os.Create(s.paths[partition])
+		if err != nil {
+			return err
+		}
+		s.files[partition] = file
+		s.writers[partition] = bufio.NewWriterSize(file, 4096)
+	}
+	if err := writeDigest(s.writers[partition], s.buffer, d); err != nil {
+		return err
+	}
+
+	cost := s.overhead
+	for _, cell := range d.Source {
+		cost += stringHeaderSize + int64(len(cell))
+	}
+	s.costs[partition] += cost
+	s.rows[partition]++
+
+	return nil
+}
+
+// writeDigest writes d in the format read by readDigests.
+// buffer must hold binary.MaxVarintLen64 bytes.
+func writeDigest(w *bufio.Writer, buffer []byte, d Digest) error {
+	writeUvarint(w, buffer, d.Key)
+	writeUvarint(w, buffer, d.Value)
+	writeUvarint(w, buffer, uint64(d.Line))
+	writeUvarint(w, buffer, uint64(d.Offset))
+	writeUvarint(w, buffer, uint64(len(d.Source)))
+	for _, cell := range d.Source {
+		writeUvarint(w, buffer, uint64(len(cell)))
+		if _, err := w.WriteString(cell); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeUvarint(w *bufio.Writer, buffer []byte, x uint64) {
+	n := binary.PutUvarint(buffer, x)
+	// errors are sticky and surface on the next WriteString or Flush
+	_, _ = w.Write(buffer[:n])
+}
+
+// flush writes all buffered digests and closes the files
+func (s *spill) flush() error {
+	for i, w := range s.writers {
+		if w == nil {
+			continue
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if err := s.files[i].Close(); err != nil {
+			return err
+		}
+		s.files[i] = nil
+	}
+
+	return nil
+}
+
+func (s *spill) close() {
+	for _, file := range s.files {
+		if file != nil {
+			_ = file.Close()
+		}
+	}
+}
+
+// stream reads back the digests of the given partitions.
+// It returns channels like Engine.StreamDigestsContext.
+func (s *spill) stream(ctx context.Context, partitions []int) (chan []Digest, chan error) {
+	maxProcs := runtime.NumCPU()
+	digestChannel := make(chan []Digest, bufferSize*maxProcs)
+	errorChannel := make(chan error, 1)
+
+	go func() {
+		defer close(errorChannel)
+		defer close(digestChannel)
+
+		for _, partition := range partitions {
+			if s.rows[partition] == 0 {
+				continue
+			}
+			if err := readPartition(ctx, s.paths[partition], digestChannel); err != nil {
+				errorChannel <- err
+				return
+			}
+		}
+		errorChannel <- nil
+	}()
+
+	return digestChannel, errorChannel
+}
+
+func readPartition(ctx context.Context, path string, digestChannel chan []Digest) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	for {
+		digests, err := readDigests(r)
+		if len(digests) > 0 {
+			select {
+			case digestChannel <- digests:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readDigests reads up to bufferSize digests.
+// It returns io.EOF once the partition is exhausted.
+func readDigests(r *bufio.Reader) ([]Digest, error) {
+	digests := make([]Digest, 0, bufferSize)
+	for len(digests) < bufferSize {
+		key, err := binary.ReadUvarint(r)
+		if err != nil {
+			return digests, err
+		}
+
+		var fields [4]uint64
+		for i := range fields {
+			if fields[i], err = binary.ReadUvarint(r); err != nil {
+				return digests, unexpected(err)
+			}
+		}
+
+		source := make([]string, fields[3])
+		for i := range source {
+			size, err := binary.ReadUvarint(r)
+			if err != nil {
+				return digests, unexpected(err)
+			}
+			cell := make([]byte, size)
+			if _, err := io.ReadFull(r, cell); err != nil {
+				return digests, unexpected(err)
+			}
+			source[i] = string(cell)
+		}
+
+		digests = append(digests, Digest{Key: key, Value: fields[0], Line: int(fields[1]), Offset: int64(fields[2]), Source: source})
+	}
+
+	return digests, nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// spillDigests digests all rows of the engine's file into a spill
+func spillDigests(ctx context.Context, engine *Engine, dir, name string, overhead int64) (*spill, error) {
+	s := newSpill(dir, name, 0, partitionCount, overhead)
+	digestChannel, errorChannel := engine.StreamDigestsContext(ctx)
+	if err := s.writeAll(digestChannel, errorChannel); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// writeAll writes the digests of digestChannel and flushes the spill.
+// Read errors are returned as they are and write errors are wrapped.
+func (s *spill) writeAll(digestChannel chan []Digest, errorChannel chan error) error {
+	var writeErr error
+	for digests := range digestChannel {
+		for _, d := range digests {
+			if writeErr == nil {
+				writeErr = s.write(d)
+			}
+		}
+	}
+
+	if err := <-errorChannel; err != nil {
+		s.close()
+		return err
+	}
+	if writeErr == nil {
+		writeErr = s.flush()
+	}
+	if writeErr != nil {
+		s.close()
+		return fmt.Errorf("error spilling to disk: %v", writeErr)
+	}
+
+	return nil
+}
+
+// split spills the rows of a partition again into count partitions
+// of the next level and removes the file of the partition
+func (s *spill) split(ctx context.Context, partition, count int) (*spill, error) {
+	split := newSpill(s.dir, fmt.Sprintf("%s-%03d", s.name, partition), s.level+1, count, s.overhead)
+	digestChannel, errorChannel := s.stream(ctx, []int{partition})
+	if err := split.writeAll(digestChannel, errorChannel); err != nil {
+		return nil, err
+	}
+	if s.rows[partition] > 0 {
+		_ = os.Remove(s.paths[partition])
+	}
+
+	return split, nil
+}
+
+// spillGroup is a group of partitions of base and delta to diff together
+type spillGroup struct {
+	base, delta *spill
+	partitions  []int
+}
+
+// groupPartitions groups consecutive partitions whose combined
+// cost fits maxMemory. A partition costlier than maxMemory is on its own.
+func groupPartitions(base, delta *spill, maxMemory int64) [][]int {
+	groups := make([][]int, 0)
+	group := make([]int, 0)
+	var cost int64
+	for i := range base.costs {
+		partitionCost := base.costs[i] + delta.costs[i]
+		if partitionCost == 0 {
+			continue
+		}
+		if len(group) > 0 && cost+partitionCost > maxMemory {
+			groups = append(groups, group)
+			group, cost = make([]int, 0), 0
+		}
+		group = append(group, i)
+		cost += partitionCost
+	}
+	if len(group) == 0 {
+		return groups
+	}
+
+	return append(groups, group)
+}
+
+// splitGroups groups the partitions of base and delta to fit maxMemory.
+// A partition costlier than maxMemory is split again with the next level
+// into twice as many partitions as its cost needs, up to partitionCount,
+// until it fits. Only the rows of a single key, or of keys whose hashes
+// collide at every level, cannot be split and are diffed on their own.
+func splitGroups(ctx context.Context, base, delta *spill, maxMemory int64) ([]spillGroup, error) {
+	groups := make([]spillGroup, 0)
+	for _, partitions := range groupPartitions(base, delta, maxMemory) {
+		partition := partitions[0]
+		cost := base.costs[partition] + delta.costs[partition]
+		if len(partitions) > 1 || cost <= maxMemory ||
+			base.rows[partition]+delta.rows[partition] < 2 || base.level >= maxSplitLevel {
+			groups = append(groups, spillGroup{base: base, delta: delta, partitions: partitions})
+			continue
+		}
+
+		count := partitionCount
+		if needed := 2 * (cost/maxMemory + 1); needed < partitionCount {
+			count = int(needed)
+		}
+		splitBase, err := base.split(ctx, partition, count)
+		if err != nil {
+			return nil, fmt.Errorf("error reading base spill: %v", err)
+		}
+		splitDelta, err := delta.split(ctx, partition, count)
+		if err != nil {
+			return nil, fmt.Errorf("error reading delta spill: %v", err)
+		}
+		split, err := splitGroups(ctx, splitBase, splitDelta, maxMemory)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, split...)
+	}
+
+	return groups, nil
+}
+
+// diffOnDisk hash-partitions base and delta into spill files and
+// diffs a group of partitions at a time to stay within MaxMemory.
+func diffOnDisk(ctx context.Context, baseConfig, deltaConfig Config, progress *progressTracker, emit func(message)) error {
+	dir, err := os.MkdirTemp(baseConfig.SpillDir, "csvdiff-")
+	if err != nil {
+		return fmt.Errorf("error creating spill directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	progress.phase(ReadingBase)
+	baseEngine := NewEngine(baseConfig)
+	baseEngine.onRead = progress.reader(Base)
+	base, err := spillDigests(ctx, baseEngine, dir, "base", baseRowOverhead)
+	if err != nil {
+		return fmt.Errorf("error processing base file: %v", err)
+	}
+
+	progress.phase(ReadingDelta)
+	deltaEngine := NewEngine(deltaConfig)
+	deltaEngine.onRead = progress.reader(Delta)
+	delta, err := spillDigests(ctx, deltaEngine, dir, "delta", deltaRowOverhead)
+	if err != nil {
+		return fmt.Errorf("error processing delta file: %v", err)
+	}
+
+	progress.phase(DiffingPartitions)
+
+	maxMemory := baseConfig.MaxMemory
+	if maxMemory < minMaxMemory {
+		maxMemory = minMaxMemory
+	}
+	groups, err := splitGroups(ctx, base, delta, maxMemory)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		baseFileDigest := NewFileDigest()
+		baseDigestChannel, baseErrorChannel := group.base.stream(ctx, group.partitions)
+		for digests := range baseDigestChannel {
+			for _, d := range digests {
+				baseFileDigest.Append(d)
+			}
+		}
+		if err := <-baseErrorChannel; err != nil {
+			return fmt.Errorf("error reading base spill: %v", err)
+		}
+
+		if err := emitBase(baseFileDigest, baseConfig, emit); err != nil {
+			return err
+		}
+
+		deltaDigestChannel, deltaErrorChannel := group.delta.stream(ctx, group.partitions)
+		duplicates := emitDifferences(ctx, baseFileDigest, deltaDigestChannel, baseConfig, emit)
+		if err := <-deltaErrorChannel; err != nil {
+			return fmt.Errorf("error reading delta spill: %v", err)
+		}
+		if err := checkDelta(duplicates, deltaConfig); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitGroups(t *testing.T) {
+	var base, delta strings.Builder
+	for i := 0; i < 40000; i++ {
+		base.WriteString(fmt.Sprintf("%d,value-%d\n", i, i))
+		delta.WriteString(fmt.Sprintf("%d,value-%d\n", i, i%7))
+	}
+	config := func(csv string) Config {
+		return Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ','}
+	}
+	ctx, dir, maxMemory := context.Background(), t.TempDir(), int64(minMaxMemory)
+
+	baseSpill, err := spillDigests(ctx, NewEngine(config(base.String())), dir, "base", baseRowOverhead)
+	assert.NoError(t, err)
+	deltaSpill, err := spillDigests(ctx, NewEngine(config(delta.String())), dir, "delta", deltaRowOverhead)
+	assert.NoError(t, err)
+
+	var total int64
+	for i := 0; i < partitionCount; i++ {
+		total += baseSpill.costs[i] + deltaSpill.costs[i]
+	}
+	assert.Greater(t, total, partitionCount*maxMemory)
+
+	groups, err := splitGroups(ctx, baseSpill, deltaSpill, maxMemory)
+
+	assert.NoError(t, err)
+	rows := 0
+	for _, group := range groups {
+		var cost int64
+		for _, partition := range group.partitions {
+			cost += group.base.costs[partition] + group.delta.costs[partition]
+			rows += group.base.rows[partition] + group.delta.rows[partition]
+		}
+		assert.LessOrEqual(t, cost, maxMemory)
+	}
+	assert.Equal(t, 80000, rows)
+}
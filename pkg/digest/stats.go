@@ -0,0 +1,108 @@
+package digest
+
+import (
+	"context"
+	"sort"
+)
+
+// Stats counts the differences between two csv files
+// without holding the rows. Columns are in the order of their index.
+//
+// Unknown counts the modifications whose changed columns are not known,
+// as in a snapshot without rows. They are left out of Columns.
+type Stats struct {
+	Additions     int
+	Modifications int
+	Deletions     int
+	Unchanged     int
+	Duplicates    int
+	Rekeyings     int
+	Moves         int
+	Unknown       int
+	Columns       []ColumnStats
+}
+
+// ColumnStats counts the modifications that changed a column.
+// FromEmpty and ToEmpty count the changes from an empty cell
+// to a value and from a value to an empty cell.
+type ColumnStats struct {
+	Index     int
+	Name      string
+	Compared  bool
+	Changes   int
+	FromEmpty int
+	ToEmpty   int
+}
+
+// Changed tells if rows were added, modified, deleted, rekeyed or moved
+func (s Stats) Changed() bool {
+	return s.Additions > 0 || s.Modifications > 0 || s.Deletions > 0 || s.Rekeyings > 0 || s.Moves > 0
+}
+
+// DiffStats diffs baseConfig and deltaConfig like Diff and counts the
+// differences and the unchanged rows. Only the counts are held in memory.
+func DiffStats(baseConfig, deltaConfig Config) (Stats, error) {
+	return DiffStatsContext(context.Background(), baseConfig, deltaConfig)
+}
+
+// DiffStatsContext is DiffStats stopping once ctx is done.
+// It returns the error of ctx if the diff did not complete.
+func DiffStatsContext(ctx context.Context, baseConfig, deltaConfig Config) (Stats, error) {
+	baseConfig.Unchanged = true
+	changeChannel, errorChannel := StreamDiffContext(ctx, baseConfig, deltaConfig)
+
+	var stats Stats
+	columns := make(map[int]*ColumnStats)
+	for change := range changeChannel {
+		switch change.Type {
+		case AdditionChange:
+			stats.Additions++
+		case ModificationChange:
+			stats.Modifications++
+			if change.Modification.Columns == nil {
+				stats.Unknown++
+			}
+			for _, column := range change.Modification.Columns {
+				count, found := columns[column.Index]
+				if !found {
+					count = &ColumnStats{Index: column.Index, Name: column.Name, Compared: column.Compared}
+					columns[column.Index] = count
+				}
+				count.add(change.Modification, column.Index)
+			}
+		case DeletionChange:
+			stats.Deletions++
+		case UnchangedChange:
+			stats.Unchanged++
+		case DuplicateChange:
+			stats.Duplicates++
+		case RekeyChange:
+			stats.Rekeyings++
+		case MoveChange:
+			stats.Moves++
+		}
+	}
+	if err := <-errorChannel; err != nil {
+		return Stats{}, err
+	}
+
+	stats.Columns = make([]ColumnStats, 0, len(columns))
+	for _, count := range columns {
+		stats.Columns = append(stats.Columns, *count)
+	}
+	sort.Slice(stats.Columns, func(i, j int) bool { return stats.Columns[i].Index < stats.Columns[j].Index })
+
+	return stats, nil
+}
+
+// add counts the change of modification to the cell at i
+func (c *ColumnStats) add(modification Modification, i int) {
+	c.Changes++
+	original, current := cell(modification.Original, i), cell(modification.Current, i)
+	switch {
+	case original == "":
+		c.FromEmpty++
+	case current == "":
+		c.ToEmpty++
+	}
+}
@@ -0,0 +1,72 @@
+package digest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffStats(t *testing.T) {
+	base := `id,name,role,team
+1,tom,developer,core
+2,ryan,,core
+3,emin,pm,
+4,ana,ops,infra
+5,joe,qa,infra
+`
+	delta := `id,name,role,team
+1,tom,lead,core
+2,ryan,qa,core
+3,emin,,
+4,ana,ops,infra
+5,joseph,qa,infra
+6,new,dev,core
+`
+	config := func(csv string) digest.Config {
+		return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Value: []int{1, 2}, Separator: ',', SkipHeader: true}
+	}
+	want := digest.Stats{
+		Additions:     1,
+		Modifications: 4,
+		Unchanged:     1,
+		Columns: []digest.ColumnStats{
+			{Index: 1, Name: "name", Compared: true, Changes: 1},
+			{Index: 2, Name: "role", Compared: true, Changes: 3, FromEmpty: 1, ToEmpty: 1},
+		},
+	}
+
+	modes := []struct {
+		name   string
+		config func(digest.Config) digest.Config
+	}{
+		{"in memory", func(c digest.Config) digest.Config { return c }},
+		{"with an index", func(c digest.Config) digest.Config { c.Index = true; return c }},
+		{"as a multiset", func(c digest.Config) digest.Config { c.Duplicates = digest.DuplicateMultiset; return c }},
+		{"on disk", func(c digest.Config) digest.Config { c.MaxMemory = 1; return c }},
+		{"sorted", func(c digest.Config) digest.Config { c.Sorted = true; return c }},
+		{"with moves", func(c digest.Config) digest.Config { c.Reorders = true; return c }},
+	}
+	for _, mode := range modes {
+		t.Run("should count the changes per column "+mode.name, func(t *testing.T) {
+			baseConfig := mode.config(config(base))
+			baseConfig.Header = []string{"id", "name", "role", "team"}
+			got, err := digest.DiffStats(baseConfig, config(delta))
+
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+
+	t.Run("should not send unchanged rows unless asked", func(t *testing.T) {
+		baseConfig := config(base)
+		baseConfig.Reorders = true
+		changeChannel, errorChannel := digest.StreamDiff(baseConfig, config(delta))
+
+		for change := range changeChannel {
+			assert.NotEqual(t, digest.UnchangedChange, change.Type)
+		}
+		assert.NoError(t, <-errorChannel)
+	})
+}
@@ -0,0 +1,220 @@
+package digest
+
+import "context"
+
+// ChangeType tells which kind of difference a Change is
+type ChangeType int
+
+const (
+	// AdditionChange is a row appearing in delta but missing in base
+	AdditionChange ChangeType = iota
+	// ModificationChange is a row whose values changed in delta
+	ModificationChange
+	// DeletionChange is a row appearing in base but missing in delta
+	DeletionChange
+	// DuplicateChange is a primary key repeated within a file
+	DuplicateChange
+	// RekeyChange is a row whose primary key changed in delta
+	// while its values did not. Only found if Config.Rekeys is set.
+	RekeyChange
+	// MoveChange is a row whose place among the other rows changed
+	// in delta. Only found if Config.Reorders is set.
+	MoveChange
+	// UnchangedChange is a row found in both files with the same values.
+	// Only sent if Config.Unchanged is set. It holds no row.
+	UnchangedChange
+)
+
+// Change is a single difference found by StreamDiff.
+// Only the field matching Type is set.
+type Change struct {
+	Type         ChangeType
+	Addition     Addition
+	Modification Modification
+	Deletion     Deletion
+	Duplicate    Duplicate
+	Rekeying     Rekeying
+	Move         Move
+}
+
+// row returns the cells the change is ordered by
+func (c Change) row() []string {
+	switch c.Type {
+	case AdditionChange:
+		return c.Addition.Row
+	case ModificationChange:
+		return c.Modification.Current
+	case DeletionChange:
+		return c.Deletion.Row
+	case RekeyChange:
+		return c.Rekeying.Current
+	case MoveChange:
+		return c.Move.Row
+	default:
+		return nil
+	}
+}
+
+// position returns the position the change is ordered by,
+// which is in delta for all but deletions
+func (c Change) position() Position {
+	switch c.Type {
+	case AdditionChange:
+		return c.Addition.Position
+	case ModificationChange:
+		return c.Modification.CurrentPosition
+	case DeletionChange:
+		return c.Deletion.Position
+	case RekeyChange:
+		return c.Rekeying.CurrentPosition
+	case MoveChange:
+		return c.Move.CurrentPosition
+	default:
+		return Position{}
+	}
+}
+
+// change turns m into a Change. config is the config of base.
+func (m message) change(config Config) Change {
+	key := config.Key
+
+	switch m._type {
+	case addition:
+		return Change{Type: AdditionChange, Addition: Addition{Row: m.current.Source, Position: positionOf(m.current)}}
+	case modification:
+		return Change{Type: ModificationChange, Modification: Modification{
+			Original:         m.original.Source,
+			Current:          m.current.Source,
+			OriginalPosition: positionOf(m.original),
+			CurrentPosition:  positionOf(m.current),
+			Columns:          m.changedColumns(config),
+		}}
+	case deletion:
+		return Change{Type: DeletionChange, Deletion: Deletion{Row: m.current.Source, Position: positionOf(m.current)}}
+	case rekey:
+		return Change{Type: RekeyChange, Rekeying: Rekeying{
+			OriginalKey:      key.pluck(m.original.Source),
+			CurrentKey:       key.pluck(m.current.Source),
+			Original:         m.original.Source,
+			Current:          m.current.Source,
+			OriginalPosition: positionOf(m.original),
+			CurrentPosition:  positionOf(m.current),
+		}}
+	case move:
+		return Change{Type: MoveChange, Move: Move{
+			Row:              m.current.Source,
+			OriginalIndex:    m.ordinals[Base],
+			CurrentIndex:     m.ordinals[Delta],
+			OriginalPosition: positionOf(m.original),
+			CurrentPosition:  positionOf(m.current),
+		}}
+	case unchanged:
+		return Change{Type: UnchangedChange}
+	default:
+		return Change{Type: DuplicateChange, Duplicate: m.duplicate}
+	}
+}
+
+// changedColumns returns the columns whose cells differ between
+// the rows of a modification, or nil if the base row holds only its key
+func (m message) changedColumns(config Config) []ColumnChange {
+	if m.keyOnly {
+		return nil
+	}
+
+	original, current := m.original.Source, m.current.Source
+	var columns []ColumnChange
+	for i := 0; i < len(original) || i < len(current); i++ {
+		if cell(original, i) == cell(current, i) {
+			continue
+		}
+		column := ColumnChange{Index: i, Compared: len(config.Value) == 0 || config.Value.Contains(i)}
+		if i < len(config.Header) {
+			column.Name = config.Header[i]
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// StreamDiff finds the differences between baseConfig and deltaConfig
+// and sends each change on the change channel as soon as it is found,
+// without holding the differences in memory. Changes are not ordered.
+//
+// Once the change channel is closed, the error channel receives
+// nil or the error that stopped the diff. Changes sent before
+// an error are not retracted.
+//
+// The duplicate policy of each config applies to its own file.
+// Whether rows are diffed as a multiset, whether hash matches are
+// verified and whether the diff is sorted or spills to disk is decided
+// by baseConfig. So is the Sample of rows diffed in both files.
+//
+// If baseConfig.Rekeys is set, additions and deletions are held back
+// until the end to pair them up as re-keyed rows. If baseConfig.Reorders
+// is set, moves are found once all rows are diffed.
+func StreamDiff(baseConfig, deltaConfig Config) (chan Change, chan error) {
+	return StreamDiffContext(context.Background(), baseConfig, deltaConfig)
+}
+
+// StreamDiffContext is StreamDiff stopping once ctx is done.
+// All go routines exit even if the change channel is no longer
+// read, and the error channel receives the error of ctx.
+func StreamDiffContext(ctx context.Context, baseConfig, deltaConfig Config) (chan Change, chan error) {
+	changeChannel := make(chan Change, bufferSize)
+	errorChannel := make(chan error, 1)
+
+	// both files are sampled alike so that sampled keys meet
+	deltaConfig.Sample = baseConfig.Sample
+
+	go func() {
+		progress := newProgressTracker(baseConfig.Progress)
+		emit := func(msg message) {
+			if msg._type == unchanged && !baseConfig.Unchanged {
+				return
+			}
+			select {
+			case changeChannel <- msg.change(baseConfig):
+			case <-ctx.Done():
+			}
+		}
+
+		var rekeys *rekeyMatcher
+		if baseConfig.Rekeys {
+			rekeys = newRekeyMatcher(baseConfig, emit)
+			emit = rekeys.hold
+		}
+		var reorders *reorderTracker
+		if baseConfig.Reorders {
+			reorders = newReorderTracker(emit)
+			emit = reorders.track
+		}
+
+		var err error
+		switch {
+		case baseConfig.Snapshot:
+			err = diffInMemory(ctx, baseConfig, deltaConfig, progress, emit)
+		case baseConfig.Sorted:
+			err = diffSorted(ctx, baseConfig, deltaConfig, progress, emit)
+		case baseConfig.MaxMemory > 0:
+			err = diffOnDisk(ctx, baseConfig, deltaConfig, progress, emit)
+		case baseConfig.Index:
+			err = diffIndexed(ctx, baseConfig, deltaConfig, progress, emit)
+		default:
+			err = diffInMemory(ctx, baseConfig, deltaConfig, progress, emit)
+		}
+		if err == nil && ctx.Err() == nil {
+			reorders.flush()
+			rekeys.flush()
+		}
+
+		close(changeChannel)
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		errorChannel <- err
+		close(errorChannel)
+	}()
+
+	return changeChannel, errorChannel
+}
@@ -0,0 +1,132 @@
+package digest_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/aswinkarthik/csvdiff/pkg/digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamDiff(t *testing.T) {
+	base := `1,one
+2,two
+3,three
+`
+	delta := `1,one
+2,two-modified
+4,four
+4,four-again
+`
+	config := func(csv string) digest.Config {
+		return digest.Config{
+			Reader:    strings.NewReader(csv),
+			Key:       []int{0},
+			Separator: ',',
+		}
+	}
+
+	t.Run("should stream each change", func(t *testing.T) {
+		changeChannel, errorChannel := digest.StreamDiff(config(base), config(delta))
+
+		var changes []digest.Change
+		for change := range changeChannel {
+			changes = append(changes, change)
+		}
+
+		assert.NoError(t, <-errorChannel)
+		assert.ElementsMatch(t, []digest.Change{
+			{
+				Type:     digest.AdditionChange,
+				Addition: digest.Addition{Row: []string{"4", "four"}, Position: digest.Position{Line: 3, Offset: 21}},
+			},
+			{
+				Type:     digest.AdditionChange,
+				Addition: digest.Addition{Row: []string{"4", "four-again"}, Position: digest.Position{Line: 4, Offset: 28}},
+			},
+			{
+				Type: digest.ModificationChange,
+				Modification: digest.Modification{
+					Original:         []string{"2", "two"},
+					Current:          []string{"2", "two-modified"},
+					OriginalPosition: digest.Position{Line: 2, Offset: 6},
+					CurrentPosition:  digest.Position{Line: 2, Offset: 6},
+					Columns:          []digest.ColumnChange{{Index: 1, Compared: true}},
+				},
+			},
+			{
+				Type:     digest.DeletionChange,
+				Deletion: digest.Deletion{Row: []string{"3", "three"}, Position: digest.Position{Line: 3, Offset: 12}},
+			},
+			{
+				Type:      digest.DuplicateChange,
+				Duplicate: digest.Duplicate{File: digest.Delta, Key: []string{"4"}, Lines: []int{3, 4}},
+			},
+		}, changes)
+	})
+
+	t.Run("should send the error after the last change", func(t *testing.T) {
+		deltaConfig := config(delta)
+		deltaConfig.Duplicates = digest.DuplicateFail
+
+		changeChannel, errorChannel := digest.StreamDiff(config(base), deltaConfig)
+
+		count := 0
+		for range changeChannel {
+			count++
+		}
+
+		assert.EqualError(t, <-errorChannel, `duplicate primary key "4" on lines 3, 4 of delta file`)
+		assert.Equal(t, 5, count)
+	})
+}
+
+func TestStreamDiffContext(t *testing.T) {
+	var base, delta strings.Builder
+	for i := 0; i < 20000; i++ {
+		base.WriteString(fmt.Sprintf("%d,value-%d\n", i, i))
+		delta.WriteString(fmt.Sprintf("%d,modified-%d\n", i, i))
+	}
+
+	for _, mode := range []struct {
+		name   string
+		config func(csv string) digest.Config
+	}{
+		{"in memory", func(csv string) digest.Config {
+			return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ','}
+		}},
+		{"on disk", func(csv string) digest.Config {
+			return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ',', MaxMemory: 64 * 1024, SpillDir: t.TempDir()}
+		}},
+		{"sorted", func(csv string) digest.Config {
+			return digest.Config{Reader: strings.NewReader(csv), Key: []int{0}, Separator: ',', Sorted: true, Collation: digest.NumericCollation}
+		}},
+	} {
+		t.Run(fmt.Sprintf("should stop without leaking when diffing %s", mode.name), func(t *testing.T) {
+			goroutines := runtime.NumGoroutine()
+			ctx, cancel := context.WithCancel(context.Background())
+
+			changeChannel, errorChannel := digest.StreamDiffContext(ctx, mode.config(base.String()), mode.config(delta.String()))
+
+			// stop reading after the first change
+			<-changeChannel
+			cancel()
+
+			assert.Equal(t, context.Canceled, <-errorChannel)
+			assertNoLeaks(t, goroutines)
+		})
+	}
+
+	t.Run("should not diff once cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := digest.DiffContext(ctx, digest.Config{Reader: strings.NewReader(base.String()), Separator: ','},
+			digest.Config{Reader: strings.NewReader(delta.String()), Separator: ','})
+
+		assert.Equal(t, context.Canceled, err)
+	})
+}
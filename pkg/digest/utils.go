@@ -5,14 +5,33 @@ import (
 	"io"
 )
 
-func getNextNLines(reader *csv.Reader) ([][]string, bool, error) {
-	lines := make([][]string, bufferSize)
+// record is a csv line along with the line number
+// and byte offset it starts at in the source file
+type record struct {
+	fields []string
+	line   int
+	offset int64
+}
+
+// skipHeader reads the first line past if the config has a header
+func skipHeader(reader *csv.Reader, config Config) error {
+	if !config.SkipHeader {
+		return nil
+	}
+	if _, err := reader.Read(); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func getNextNLines(reader *csv.Reader) ([]record, bool, error) {
+	lines := make([]record, bufferSize)
 
 	lineCount := 0
 	eofReached := false
 	for ; lineCount < bufferSize; lineCount++ {
+		offset := reader.InputOffset()
 		line, err := reader.Read()
-		lines[lineCount] = line
 		if err != nil {
 			if err == io.EOF {
 				eofReached = true
@@ -21,6 +40,8 @@ func getNextNLines(reader *csv.Reader) ([][]string, bool, error) {
 
 			return nil, true, err
 		}
+		lineNumber, _ := reader.FieldPos(0)
+		lines[lineCount] = record{fields: line, line: lineNumber, offset: offset}
 	}
 
 	return lines[:lineCount], eofReached, nil
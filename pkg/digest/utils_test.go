@@ -26,9 +26,13 @@ func TestGetNextNLines(t *testing.T) {
 		assert.False(t, eofReached)
 		assert.NoError(t, err)
 
+		var offset int64
 		for i := 0; i < bufferSize; i++ {
 			expected := []string{strconv.Itoa(i), "random-col-1", "random-col-2"}
-			assert.Equal(t, expected, lines[i])
+			assert.Equal(t, expected, lines[i].fields)
+			assert.Equal(t, i+1, lines[i].line)
+			assert.Equal(t, offset, lines[i].offset)
+			offset += int64(len(strings.Join(expected, ",")) + 1)
 		}
 
 		lines, eofReached, err = getNextNLines(csvFile)
@@ -39,7 +43,8 @@ func TestGetNextNLines(t *testing.T) {
 
 		for i := 0; i < totalLines-bufferSize; i++ {
 			expected := []string{strconv.Itoa(i + bufferSize), "random-col-1", "random-col-2"}
-			assert.Equal(t, expected, lines[i])
+			assert.Equal(t, expected, lines[i].fields)
+			assert.Equal(t, i+bufferSize+1, lines[i].line)
 		}
 	})
 